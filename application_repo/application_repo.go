@@ -1,13 +1,19 @@
 package application_repo
 
 import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
 	"github.com/cloudfoundry/cli/plugin"
 	"github.com/cloudfoundry/cli/plugin/models"
 )
 
 //ApplicationRepo - cli connection wrapper
 type ApplicationRepo struct {
-	conn plugin.CliConnection
+	conn  plugin.CliConnection
+	quiet bool
 }
 
 //NewApplicationRepo - constructor function to create cli connection wrapper
@@ -17,22 +23,38 @@ func NewApplicationRepo(conn plugin.CliConnection) *ApplicationRepo {
 	}
 }
 
+//WithoutTerminalOutput - a copy of the repo whose cf commands do not print
+//their output to the terminal
+func (repo *ApplicationRepo) WithoutTerminalOutput() *ApplicationRepo {
+	return &ApplicationRepo{
+		conn:  repo.conn,
+		quiet: true,
+	}
+}
+
+//cf - run a cf command, printing its output unless the repo is quiet
+func (repo *ApplicationRepo) cf(args ...string) error {
+	if repo.quiet {
+		_, err := repo.conn.CliCommandWithoutTerminalOutput(args...)
+		return err
+	}
+	_, err := repo.conn.CliCommand(args...)
+	return err
+}
+
 //RenameApplication - rename the application given
 func (repo *ApplicationRepo) RenameApplication(oldName, newName string) error {
-	_, err := repo.conn.CliCommand("rename", oldName, newName)
-	return err
+	return repo.cf("rename", oldName, newName)
 }
 
 //PushApplication - push the application to cf
 func (repo *ApplicationRepo) PushApplication(args []string) error {
-	_, err := repo.conn.CliCommand(args...)
-	return err
+	return repo.cf(args...)
 }
 
 //DeleteApplication - delete the application from cf
 func (repo *ApplicationRepo) DeleteApplication(appName string) error {
-	_, err := repo.conn.CliCommand("delete", appName, "-f")
-	return err
+	return repo.cf("delete", appName, "-f")
 }
 
 //ListApplications - list applications on cf
@@ -51,3 +73,119 @@ func (repo *ApplicationRepo) ListApplicationsWithOutput() (output []string, err
 	}
 	return
 }
+
+//GetApplication - get the details of an application on cf
+func (repo *ApplicationRepo) GetApplication(appName string) (plugin_models.GetAppModel, error) {
+	return repo.conn.GetApp(appName)
+}
+
+//MapRoute - map the route host.domain to the application
+func (repo *ApplicationRepo) MapRoute(appName, domain, host string) error {
+	return repo.cf(withHost([]string{"map-route", appName, domain}, host)...)
+}
+
+//UnmapRoute - unmap the route host.domain from the application
+func (repo *ApplicationRepo) UnmapRoute(appName, domain, host string) error {
+	return repo.cf(withHost([]string{"unmap-route", appName, domain}, host)...)
+}
+
+//DeleteRoute - delete the route host.domain
+func (repo *ApplicationRepo) DeleteRoute(domain, host string) error {
+	return repo.cf(append(withHost([]string{"delete-route", domain}, host), "-f")...)
+}
+
+//withHost - add the hostname flag to args unless the route is on the root domain
+func withHost(args []string, host string) []string {
+	if host == "" {
+		return args
+	}
+	return append(args, "-n", host)
+}
+
+//ScaleApplication - set the number of instances of the application
+func (repo *ApplicationRepo) ScaleApplication(appName string, instances int) error {
+	return repo.cf("scale", appName, "-i", strconv.Itoa(instances))
+}
+
+//StartApplication - start the application
+func (repo *ApplicationRepo) StartApplication(appName string) error {
+	return repo.cf("start", appName)
+}
+
+//StopApplication - stop the application
+func (repo *ApplicationRepo) StopApplication(appName string) error {
+	return repo.cf("stop", appName)
+}
+
+//SetEnv - set the environment variable name of the application, or unset it
+//when value is empty. The application is not restaged
+func (repo *ApplicationRepo) SetEnv(appName, name, value string) error {
+	if value == "" {
+		return repo.cf("unset-env", appName, name)
+	}
+	return repo.cf("set-env", appName, name, value)
+}
+
+//ApiEndpoint - the cloud controller the cli is targeting
+func (repo *ApplicationRepo) ApiEndpoint() (string, error) {
+	return repo.conn.ApiEndpoint()
+}
+
+//Target - the names of the org and space the cli is targeting
+func (repo *ApplicationRepo) Target() (org, space string, err error) {
+	currentOrg, err := repo.conn.GetCurrentOrg()
+	if err != nil {
+		return "", "", err
+	}
+	currentSpace, err := repo.conn.GetCurrentSpace()
+	if err != nil {
+		return "", "", err
+	}
+	return currentOrg.Name, currentSpace.Name, nil
+}
+
+//IsLoggedIn - whether the cli is logged in with a space targeted
+func (repo *ApplicationRepo) IsLoggedIn() (bool, error) {
+	loggedIn, err := repo.conn.IsLoggedIn()
+	if err != nil || !loggedIn {
+		return false, err
+	}
+	return repo.conn.HasSpace()
+}
+
+//ListServices - the names of the service instances in the targeted space
+func (repo *ApplicationRepo) ListServices() (names []string, err error) {
+	var services []plugin_models.GetServices_Model
+	services, err = repo.conn.GetServices()
+
+	for _, service := range services {
+		names = append(names, service.Name)
+	}
+	return
+}
+
+//GetOrgMemory - the memory quota of the targeted org and how much of it is
+//in use, in megabytes. A limit of zero or less means there is none
+func (repo *ApplicationRepo) GetOrgMemory() (limit, usage int64, err error) {
+	org, err := repo.conn.GetCurrentOrg()
+	if err != nil || org.QuotaDefinition.MemoryLimit <= 0 {
+		return 0, 0, err
+	}
+
+	output, err := repo.conn.CliCommandWithoutTerminalOutput("curl", "/v2/organizations/"+org.Guid+"/memory_usage")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var response struct {
+		MemoryUsage *int64 `json:"memory_usage_in_mb"`
+		Description string `json:"description"`
+	}
+	if err = json.Unmarshal([]byte(strings.Join(output, "\n")), &response); err != nil {
+		return 0, 0, err
+	}
+	if response.MemoryUsage == nil {
+		return 0, 0, errors.New("could not read the memory usage of " + org.Name + ": " + response.Description)
+	}
+	return org.QuotaDefinition.MemoryLimit, *response.MemoryUsage, nil
+}
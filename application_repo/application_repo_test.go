@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/xchapter7x/autopilot/application_repo"
@@ -38,6 +39,25 @@ var _ = Describe("ApplicationRepo", func() {
 		})
 	})
 
+	Describe("WithoutTerminalOutput", func() {
+		It("runs cf commands without printing their output", func() {
+			err := repo.WithoutTerminalOutput().RenameApplication("old-name", "new-name")
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+			Ω(cliConn.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(1))
+			args := cliConn.CliCommandWithoutTerminalOutputArgsForCall(0)
+			Ω(args).Should(Equal([]string{"rename", "old-name", "new-name"}))
+		})
+
+		It("returns an error if one occurs", func() {
+			cliConn.CliCommandWithoutTerminalOutputReturns([]string{}, errors.New("no app"))
+
+			err := repo.WithoutTerminalOutput().DeleteApplication("myapp")
+			Ω(err).Should(MatchError("no app"))
+		})
+	})
+
 	Describe("PushApplication", func() {
 		It("pushes an application with both a manifest and a path", func() {
 			err := repo.PushApplication([]string{"push", "myapp", "-f", "/path/to/a/manifest.yml", "-p", "/path/to/the/app"})
@@ -122,4 +142,184 @@ var _ = Describe("ApplicationRepo", func() {
 			})
 		})
 	})
+
+	Describe("GetApplication", func() {
+		It("returns the details of the application", func() {
+			cliConn.GetAppReturns(plugin_models.GetAppModel{Name: "app-name", InstanceCount: 2}, nil)
+
+			app, err := repo.GetApplication("app-name")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.InstanceCount).Should(Equal(2))
+			Ω(cliConn.GetAppArgsForCall(0)).Should(Equal("app-name"))
+		})
+
+		It("returns errors from the cli", func() {
+			cliConn.GetAppReturns(plugin_models.GetAppModel{}, errors.New("no app"))
+
+			_, err := repo.GetApplication("app-name")
+			Ω(err).Should(MatchError("no app"))
+		})
+	})
+
+	Describe("MapRoute", func() {
+		It("maps a route with a hostname", func() {
+			err := repo.MapRoute("app-name", "example.com", "www")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"map-route", "app-name", "example.com", "-n", "www"}))
+		})
+
+		It("maps a route on the root domain", func() {
+			err := repo.MapRoute("app-name", "example.com", "")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"map-route", "app-name", "example.com"}))
+		})
+
+		It("returns errors from the cli", func() {
+			cliConn.CliCommandReturns([]string{}, errors.New("bad route"))
+			Ω(repo.MapRoute("app-name", "example.com", "www")).Should(MatchError("bad route"))
+		})
+	})
+
+	Describe("UnmapRoute", func() {
+		It("unmaps the route", func() {
+			err := repo.UnmapRoute("app-name", "example.com", "www")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"unmap-route", "app-name", "example.com", "-n", "www"}))
+		})
+	})
+
+	Describe("DeleteRoute", func() {
+		It("deletes the route without prompting", func() {
+			err := repo.DeleteRoute("example.com", "www")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"delete-route", "example.com", "-n", "www", "-f"}))
+		})
+	})
+
+	Describe("ScaleApplication", func() {
+		It("scales the number of instances", func() {
+			err := repo.ScaleApplication("app-name", 3)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"scale", "app-name", "-i", "3"}))
+		})
+
+		It("returns errors from the cli", func() {
+			cliConn.CliCommandReturns([]string{}, errors.New("quota exceeded"))
+			Ω(repo.ScaleApplication("app-name", 3)).Should(MatchError("quota exceeded"))
+		})
+	})
+
+	Describe("StartApplication", func() {
+		It("starts the application", func() {
+			Ω(repo.StartApplication("app-name")).Should(Succeed())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"start", "app-name"}))
+		})
+	})
+
+	Describe("StopApplication", func() {
+		It("stops the application", func() {
+			Ω(repo.StopApplication("app-name")).Should(Succeed())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"stop", "app-name"}))
+		})
+	})
+
+	Describe("SetEnv", func() {
+		It("sets the environment variable", func() {
+			Ω(repo.SetEnv("app-name", "NAME", "value")).Should(Succeed())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"set-env", "app-name", "NAME", "value"}))
+		})
+
+		It("unsets the environment variable when the value is empty", func() {
+			Ω(repo.SetEnv("app-name", "NAME", "")).Should(Succeed())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"unset-env", "app-name", "NAME"}))
+		})
+	})
+
+	Describe("ApiEndpoint", func() {
+		It("returns the endpoint the cli is targeting", func() {
+			cliConn.ApiEndpointReturns("https://api.example.com", nil)
+
+			endpoint, err := repo.ApiEndpoint()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(endpoint).Should(Equal("https://api.example.com"))
+		})
+	})
+
+	Describe("Target", func() {
+		It("returns the names of the org and space the cli is targeting", func() {
+			org, space := plugin_models.Organization{}, plugin_models.Space{}
+			org.Name, space.Name = "my-org", "my-space"
+			cliConn.GetCurrentOrgReturns(org, nil)
+			cliConn.GetCurrentSpaceReturns(space, nil)
+
+			orgName, spaceName, err := repo.Target()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(orgName).Should(Equal("my-org"))
+			Ω(spaceName).Should(Equal("my-space"))
+		})
+	})
+
+	Describe("IsLoggedIn", func() {
+		It("is true when logged in with a space targeted", func() {
+			cliConn.IsLoggedInReturns(true, nil)
+			cliConn.HasSpaceReturns(true, nil)
+			Ω(repo.IsLoggedIn()).Should(BeTrue())
+		})
+
+		It("is false when no space is targeted", func() {
+			cliConn.IsLoggedInReturns(true, nil)
+			Ω(repo.IsLoggedIn()).Should(BeFalse())
+		})
+
+		It("is false when not logged in", func() {
+			cliConn.HasSpaceReturns(true, nil)
+			Ω(repo.IsLoggedIn()).Should(BeFalse())
+		})
+	})
+
+	Describe("ListServices", func() {
+		It("returns the names of the service instances", func() {
+			cliConn.GetServicesReturns([]plugin_models.GetServices_Model{
+				{Name: "db"},
+				{Name: "cache"},
+			}, nil)
+			Ω(repo.ListServices()).Should(Equal([]string{"db", "cache"}))
+		})
+	})
+
+	Describe("GetOrgMemory", func() {
+		BeforeEach(func() {
+			org := plugin_models.Organization{}
+			org.Guid = "org-guid"
+			org.Name = "my-org"
+			org.QuotaDefinition.MemoryLimit = 10240
+			cliConn.GetCurrentOrgReturns(org, nil)
+		})
+
+		It("returns the org's memory limit and usage", func() {
+			cliConn.CliCommandWithoutTerminalOutputReturns([]string{`{"memory_usage_in_mb": 2048}`}, nil)
+
+			limit, usage, err := repo.GetOrgMemory()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(limit).Should(Equal(int64(10240)))
+			Ω(usage).Should(Equal(int64(2048)))
+			Ω(cliConn.CliCommandWithoutTerminalOutputArgsForCall(0)).Should(Equal([]string{"curl", "/v2/organizations/org-guid/memory_usage"}))
+		})
+
+		It("does not look up the usage when the org has no memory limit", func() {
+			cliConn.GetCurrentOrgReturns(plugin_models.Organization{}, nil)
+
+			limit, _, err := repo.GetOrgMemory()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(limit).Should(BeZero())
+			Ω(cliConn.CliCommandWithoutTerminalOutputCallCount()).Should(Equal(0))
+		})
+
+		It("returns an error when the usage cannot be read", func() {
+			cliConn.CliCommandWithoutTerminalOutputReturns([]string{`{"code": 10003, "description": "You are not authorized"}`}, nil)
+
+			_, _, err := repo.GetOrgMemory()
+			Ω(err).Should(MatchError("could not read the memory usage of my-org: You are not authorized"))
+		})
+	})
 })
@@ -0,0 +1,579 @@
+package application_repo
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+//CloudControllerRepo - talks to the cloud controller's v2 api directly with
+//the cli's endpoint and access token, instead of running cf commands. Only
+//pushes still go through the cli, which uploads and stages the app
+type CloudControllerRepo struct {
+	conn   plugin.CliConnection
+	cli    *ApplicationRepo
+	client *http.Client
+
+	mu       sync.Mutex
+	endpoint string
+	token    string
+	space    string
+}
+
+//NewCloudControllerRepo - constructor function, pushes are made with cli
+func NewCloudControllerRepo(conn plugin.CliConnection, cli *ApplicationRepo) *CloudControllerRepo {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if disabled, _ := conn.IsSSLDisabled(); disabled {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &CloudControllerRepo{
+		conn:   conn,
+		cli:    cli,
+		client: &http.Client{Transport: transport, Timeout: time.Minute},
+	}
+}
+
+//APIError - an error returned by the cloud controller
+type APIError struct {
+	Method      string
+	Path        string
+	StatusCode  int
+	Code        int    `json:"code"`
+	ErrorCode   string `json:"error_code"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	message := e.Description
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	if e.ErrorCode != "" {
+		message = fmt.Sprintf("%s (%s)", message, e.ErrorCode)
+	}
+	return fmt.Sprintf("%s %s failed with status code: %d, %s", e.Method, e.Path, e.StatusCode, message)
+}
+
+//Temporary - whether the request may succeed if it is made again
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+//NotFoundError - the app, route or domain named does not exist
+type NotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Kind, e.Name)
+}
+
+//resource - the metadata and entity of a cloud controller v2 resource
+type resource struct {
+	Metadata struct {
+		Guid string `json:"guid"`
+	} `json:"metadata"`
+	Entity json.RawMessage `json:"entity"`
+}
+
+//page - one page of a cloud controller v2 list
+type page struct {
+	NextURL   string     `json:"next_url"`
+	Resources []resource `json:"resources"`
+}
+
+//RenameApplication - rename the application given
+func (repo *CloudControllerRepo) RenameApplication(oldName, newName string) error {
+	guid, err := repo.appGuid(oldName)
+	if err != nil {
+		return err
+	}
+	return repo.do("PUT", "/v2/apps/"+guid, map[string]interface{}{"name": newName}, nil)
+}
+
+//PushApplication - push the application with the cli
+func (repo *CloudControllerRepo) PushApplication(args []string) error {
+	return repo.cli.PushApplication(args)
+}
+
+//DeleteApplication - delete the application along with its service bindings
+//and route mappings, leaving the routes in place. Like cf delete -f an app
+//which is already gone is not an error
+func (repo *CloudControllerRepo) DeleteApplication(appName string) error {
+	guid, err := repo.appGuid(appName)
+	if _, ok := err.(*NotFoundError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = repo.do("DELETE", "/v2/apps/"+guid+"?recursive=true", nil, nil)
+	if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+//ListApplications - check the applications in the space can be listed
+func (repo *CloudControllerRepo) ListApplications() error {
+	_, err := repo.ListApplicationsWithOutput()
+	return err
+}
+
+//ListApplicationsWithOutput - the names of the applications in the space
+func (repo *CloudControllerRepo) ListApplicationsWithOutput() (names []string, err error) {
+	space, err := repo.spaceGuid()
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.list("/v2/spaces/"+space+"/apps", func(r resource) error {
+		var app struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(r.Entity, &app); err != nil {
+			return err
+		}
+		names = append(names, app.Name)
+		return nil
+	})
+	return
+}
+
+//GetApplication - get the details of an application, in the same shape as
+//the cli gives them
+func (repo *CloudControllerRepo) GetApplication(appName string) (plugin_models.GetAppModel, error) {
+	guid, err := repo.appGuid(appName)
+	if err != nil {
+		return plugin_models.GetAppModel{}, err
+	}
+
+	var summary struct {
+		Guid               string                 `json:"guid"`
+		Name               string                 `json:"name"`
+		State              string                 `json:"state"`
+		Memory             int64                  `json:"memory"`
+		DiskQuota          int64                  `json:"disk_quota"`
+		Instances          int                    `json:"instances"`
+		RunningInstances   int                    `json:"running_instances"`
+		Buildpack          string                 `json:"buildpack"`
+		Command            string                 `json:"command"`
+		HealthCheckTimeout int                    `json:"health_check_timeout"`
+		SpaceGuid          string                 `json:"space_guid"`
+		Environment        map[string]interface{} `json:"environment_json"`
+		Routes             []struct {
+			Guid   string `json:"guid"`
+			Host   string `json:"host"`
+			Domain struct {
+				Guid string `json:"guid"`
+				Name string `json:"name"`
+			} `json:"domain"`
+		} `json:"routes"`
+		Services []struct {
+			Guid string `json:"guid"`
+			Name string `json:"name"`
+		} `json:"services"`
+	}
+	if err = repo.do("GET", "/v2/apps/"+guid+"/summary", nil, &summary); err != nil {
+		return plugin_models.GetAppModel{}, err
+	}
+
+	app := plugin_models.GetAppModel{
+		Guid:               summary.Guid,
+		Name:               summary.Name,
+		State:              strings.ToLower(summary.State),
+		Memory:             summary.Memory,
+		DiskQuota:          summary.DiskQuota,
+		InstanceCount:      summary.Instances,
+		RunningInstances:   summary.RunningInstances,
+		BuildpackUrl:       summary.Buildpack,
+		Command:            summary.Command,
+		HealthCheckTimeout: summary.HealthCheckTimeout,
+		SpaceGuid:          summary.SpaceGuid,
+		EnvironmentVars:    summary.Environment,
+	}
+	for _, route := range summary.Routes {
+		app.Routes = append(app.Routes, plugin_models.GetApp_RouteSummary{
+			Guid:   route.Guid,
+			Host:   route.Host,
+			Domain: plugin_models.GetApp_DomainFields{Guid: route.Domain.Guid, Name: route.Domain.Name},
+		})
+	}
+	for _, service := range summary.Services {
+		app.Services = append(app.Services, plugin_models.GetApp_ServiceSummary{Guid: service.Guid, Name: service.Name})
+	}
+
+	if app.State == "started" {
+		if app.Instances, err = repo.instances(guid); err != nil {
+			return plugin_models.GetAppModel{}, err
+		}
+	}
+	return app, nil
+}
+
+//instances - the state of each instance of the app, in index order
+func (repo *CloudControllerRepo) instances(guid string) ([]plugin_models.GetApp_AppInstanceFields, error) {
+	var byIndex map[string]struct {
+		State string  `json:"state"`
+		Since float64 `json:"since"`
+	}
+	err := repo.do("GET", "/v2/apps/"+guid+"/instances", nil, &byIndex)
+	if apiErr, ok := err.(*APIError); ok && apiErr.ErrorCode == "CF-NotStaged" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []int{}
+	for key := range byIndex {
+		index, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected instance index %q", key)
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	instances := []plugin_models.GetApp_AppInstanceFields{}
+	for _, index := range indexes {
+		instance := byIndex[strconv.Itoa(index)]
+		seconds := int64(instance.Since)
+		instances = append(instances, plugin_models.GetApp_AppInstanceFields{
+			State: strings.ToLower(instance.State),
+			Since: time.Unix(seconds, int64((instance.Since-float64(seconds))*1e9)),
+		})
+	}
+	return instances, nil
+}
+
+//MapRoute - map the route host.domain to the application, creating the
+//route when it does not exist
+func (repo *CloudControllerRepo) MapRoute(appName, domain, host string) error {
+	appGuid, err := repo.appGuid(appName)
+	if err != nil {
+		return err
+	}
+
+	routeGuid, err := repo.routeGuid(domain, host)
+	if _, ok := err.(*NotFoundError); ok {
+		routeGuid, err = repo.createRoute(domain, host)
+	}
+	if err != nil {
+		return err
+	}
+	return repo.do("PUT", "/v2/routes/"+routeGuid+"/apps/"+appGuid, nil, nil)
+}
+
+//UnmapRoute - unmap the route host.domain from the application
+func (repo *CloudControllerRepo) UnmapRoute(appName, domain, host string) error {
+	appGuid, err := repo.appGuid(appName)
+	if err != nil {
+		return err
+	}
+	routeGuid, err := repo.routeGuid(domain, host)
+	if err != nil {
+		return err
+	}
+	return repo.do("DELETE", "/v2/routes/"+routeGuid+"/apps/"+appGuid, nil, nil)
+}
+
+//DeleteRoute - delete the route host.domain
+func (repo *CloudControllerRepo) DeleteRoute(domain, host string) error {
+	routeGuid, err := repo.routeGuid(domain, host)
+	if err != nil {
+		return err
+	}
+	return repo.do("DELETE", "/v2/routes/"+routeGuid, nil, nil)
+}
+
+//ScaleApplication - set the number of instances of the application
+func (repo *CloudControllerRepo) ScaleApplication(appName string, instances int) error {
+	return repo.update(appName, map[string]interface{}{"instances": instances})
+}
+
+//StartApplication - ask for the application to be started, the health
+//checks wait for it to be running
+func (repo *CloudControllerRepo) StartApplication(appName string) error {
+	return repo.update(appName, map[string]interface{}{"state": "STARTED"})
+}
+
+//StopApplication - stop the application
+func (repo *CloudControllerRepo) StopApplication(appName string) error {
+	return repo.update(appName, map[string]interface{}{"state": "STOPPED"})
+}
+
+//SetEnv - set the environment variable name of the application, or unset it
+//when value is empty, keeping the rest of its environment
+func (repo *CloudControllerRepo) SetEnv(appName, name, value string) error {
+	app, err := repo.GetApplication(appName)
+	if err != nil {
+		return err
+	}
+
+	env := map[string]interface{}{}
+	for key, current := range app.EnvironmentVars {
+		env[key] = current
+	}
+	if value == "" {
+		delete(env, name)
+	} else {
+		env[name] = value
+	}
+	return repo.update(appName, map[string]interface{}{"environment_json": env})
+}
+
+//ApiEndpoint - the cloud controller the cli is targeting
+func (repo *CloudControllerRepo) ApiEndpoint() (string, error) {
+	return repo.conn.ApiEndpoint()
+}
+
+//Target - the names of the org and space the cli is targeting
+func (repo *CloudControllerRepo) Target() (org, space string, err error) {
+	return repo.cli.Target()
+}
+
+//IsLoggedIn - whether the cli is logged in with a space targeted
+func (repo *CloudControllerRepo) IsLoggedIn() (bool, error) {
+	return repo.cli.IsLoggedIn()
+}
+
+//ListServices - the names of the service instances in the targeted space
+func (repo *CloudControllerRepo) ListServices() (names []string, err error) {
+	space, err := repo.spaceGuid()
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.list("/v2/spaces/"+space+"/service_instances", func(r resource) error {
+		var service struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(r.Entity, &service); err != nil {
+			return err
+		}
+		names = append(names, service.Name)
+		return nil
+	})
+	return
+}
+
+//GetOrgMemory - the memory quota of the targeted org and how much of it is
+//in use, in megabytes. A limit of zero or less means there is none
+func (repo *CloudControllerRepo) GetOrgMemory() (limit, usage int64, err error) {
+	org, err := repo.conn.GetCurrentOrg()
+	if err != nil || org.QuotaDefinition.MemoryLimit <= 0 {
+		return 0, 0, err
+	}
+
+	var response struct {
+		MemoryUsage int64 `json:"memory_usage_in_mb"`
+	}
+	if err = repo.do("GET", "/v2/organizations/"+org.Guid+"/memory_usage", nil, &response); err != nil {
+		return 0, 0, err
+	}
+	return org.QuotaDefinition.MemoryLimit, response.MemoryUsage, nil
+}
+
+//update - change the given fields of the app
+func (repo *CloudControllerRepo) update(appName string, fields map[string]interface{}) error {
+	guid, err := repo.appGuid(appName)
+	if err != nil {
+		return err
+	}
+	return repo.do("PUT", "/v2/apps/"+guid, fields, nil)
+}
+
+//appGuid - the guid of the app with the given name in the targeted space
+func (repo *CloudControllerRepo) appGuid(appName string) (string, error) {
+	space, err := repo.spaceGuid()
+	if err != nil {
+		return "", err
+	}
+	return repo.find("/v2/spaces/"+space+"/apps?q="+url.QueryEscape("name:"+appName), "app", appName)
+}
+
+//routeGuid - the guid of the route host.domain
+func (repo *CloudControllerRepo) routeGuid(domain, host string) (string, error) {
+	domainGuid, err := repo.domainGuid(domain)
+	if err != nil {
+		return "", err
+	}
+	query := "?q=" + url.QueryEscape("host:"+host) + "&q=" + url.QueryEscape("domain_guid:"+domainGuid)
+	return repo.find("/v2/routes"+query, "route", routeName(domain, host))
+}
+
+//createRoute - create the route host.domain in the targeted space
+func (repo *CloudControllerRepo) createRoute(domain, host string) (string, error) {
+	domainGuid, err := repo.domainGuid(domain)
+	if err != nil {
+		return "", err
+	}
+	space, err := repo.spaceGuid()
+	if err != nil {
+		return "", err
+	}
+
+	var created resource
+	err = repo.do("POST", "/v2/routes", map[string]interface{}{
+		"host":        host,
+		"domain_guid": domainGuid,
+		"space_guid":  space,
+	}, &created)
+	return created.Metadata.Guid, err
+}
+
+//domainGuid - the guid of the shared or private domain with the given name
+func (repo *CloudControllerRepo) domainGuid(domain string) (string, error) {
+	query := "?q=" + url.QueryEscape("name:"+domain)
+	guid, err := repo.find("/v2/shared_domains"+query, "domain", domain)
+	if _, ok := err.(*NotFoundError); ok {
+		return repo.find("/v2/private_domains"+query, "domain", domain)
+	}
+	return guid, err
+}
+
+//find - the guid of the only resource listed at path
+func (repo *CloudControllerRepo) find(path, kind, name string) (string, error) {
+	var found page
+	if err := repo.do("GET", path, nil, &found); err != nil {
+		return "", err
+	}
+	if len(found.Resources) == 0 {
+		return "", &NotFoundError{Kind: kind, Name: name}
+	}
+	return found.Resources[0].Metadata.Guid, nil
+}
+
+//list - call each with every resource listed at path, following the pages
+func (repo *CloudControllerRepo) list(path string, each func(resource) error) error {
+	for path != "" {
+		var current page
+		if err := repo.do("GET", path, nil, &current); err != nil {
+			return err
+		}
+		for _, r := range current.Resources {
+			if err := each(r); err != nil {
+				return err
+			}
+		}
+		path = current.NextURL
+	}
+	return nil
+}
+
+//spaceGuid - the guid of the targeted space
+func (repo *CloudControllerRepo) spaceGuid() (string, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.space == "" {
+		space, err := repo.conn.GetCurrentSpace()
+		if err != nil {
+			return "", err
+		}
+		repo.space = space.Guid
+	}
+	return repo.space, nil
+}
+
+//do - make a request of the cloud controller, sending body and decoding the
+//response into result when they are not nil. An expired token is refreshed
+//from the cli once
+func (repo *CloudControllerRepo) do(method, path string, body, result interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	response, err := repo.send(method, path, data, false)
+	if err == nil && response.StatusCode == http.StatusUnauthorized {
+		response.Body.Close()
+		response, err = repo.send(method, path, data, true)
+	}
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		apiErr := &APIError{Method: method, Path: path, StatusCode: response.StatusCode}
+		json.NewDecoder(response.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if result == nil {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+//send - make a single request with the cli's access token
+func (repo *CloudControllerRepo) send(method, path string, data []byte, refreshToken bool) (*http.Response, error) {
+	endpoint, token, err := repo.credentials(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(method, endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", token)
+	request.Header.Set("Accept", "application/json")
+	if data != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	return repo.client.Do(request)
+}
+
+//credentials - the api endpoint and access token, asking the cli for them
+//the first time or when the token needs to be refreshed
+func (repo *CloudControllerRepo) credentials(refreshToken bool) (endpoint, token string, err error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	if repo.endpoint == "" {
+		if repo.endpoint, err = repo.conn.ApiEndpoint(); err != nil {
+			return "", "", err
+		}
+		repo.endpoint = strings.TrimRight(repo.endpoint, "/")
+	}
+	if repo.token == "" || refreshToken {
+		if repo.token, err = repo.conn.AccessToken(); err != nil {
+			return "", "", err
+		}
+	}
+	return repo.endpoint, repo.token, nil
+}
+
+func routeName(domain, host string) string {
+	if host == "" {
+		return domain
+	}
+	return host + "." + domain
+}
@@ -0,0 +1,425 @@
+package application_repo_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/xchapter7x/autopilot/application_repo"
+)
+
+//standInApp - an app as the stand-in cloud controller keeps it
+type standInApp struct {
+	Guid      string
+	Name      string
+	State     string
+	Instances int
+	Memory    int64
+	Routes    []string
+	Env       map[string]interface{}
+}
+
+//standInCC - just enough of the cloud controller v2 api to exercise the repo
+type standInCC struct {
+	sync.Mutex
+	apps      map[string]*standInApp
+	routes    map[string]string
+	services  []string
+	token     string
+	requests  []string
+	failWith  int
+	pageSize  int
+	nextRoute int
+}
+
+func newStandInCC() *standInCC {
+	return &standInCC{
+		apps: map[string]*standInApp{
+			"app-1": {Guid: "app-1", Name: "myapp", State: "STARTED", Instances: 2, Memory: 256, Routes: []string{"route-1"}, Env: map[string]interface{}{"LOG_LEVEL": "debug"}},
+			"app-2": {Guid: "app-2", Name: "other", State: "STOPPED", Instances: 1, Memory: 128},
+		},
+		routes:   map[string]string{"route-1": "myapp"},
+		services: []string{"db", "cache"},
+		token:    "bearer good",
+		pageSize: 100,
+	}
+}
+
+func (cc *standInCC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cc.Lock()
+	defer cc.Unlock()
+	cc.requests = append(cc.requests, r.Method+" "+r.URL.RequestURI())
+
+	if r.Header.Get("Authorization") != cc.token {
+		cc.fail(w, http.StatusUnauthorized, 1000, "CF-InvalidAuthToken", "Invalid Auth Token")
+		return
+	}
+	if cc.failWith != 0 {
+		cc.fail(w, cc.failWith, 10001, "CF-ServiceUnavailable", "try again later")
+		return
+	}
+
+	path := r.URL.Path
+	switch {
+	case r.Method == "GET" && path == "/v2/spaces/space-guid/apps":
+		cc.listApps(w, r)
+	case r.Method == "GET" && strings.HasSuffix(path, "/summary"):
+		cc.summary(w, cc.apps[strings.Split(path, "/")[3]])
+	case r.Method == "GET" && strings.HasSuffix(path, "/instances"):
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"1": map[string]interface{}{"state": "RUNNING", "since": 1500000100.5},
+			"0": map[string]interface{}{"state": "CRASHED", "since": 1500000000.0},
+		})
+	case r.Method == "PUT" && strings.HasPrefix(path, "/v2/apps/"):
+		var fields struct {
+			Name      *string
+			State     *string
+			Instances *int
+			Env       *map[string]interface{} `json:"environment_json"`
+		}
+		json.NewDecoder(r.Body).Decode(&fields)
+		app := cc.apps[strings.TrimPrefix(path, "/v2/apps/")]
+		if fields.Name != nil {
+			app.Name = *fields.Name
+		}
+		if fields.State != nil {
+			app.State = *fields.State
+		}
+		if fields.Instances != nil {
+			app.Instances = *fields.Instances
+		}
+		if fields.Env != nil {
+			app.Env = *fields.Env
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "{}")
+	case r.Method == "DELETE" && strings.HasPrefix(path, "/v2/apps/"):
+		delete(cc.apps, strings.TrimPrefix(path, "/v2/apps/"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && path == "/v2/shared_domains":
+		cc.page(w, nil, "")
+	case r.Method == "GET" && path == "/v2/private_domains":
+		cc.page(w, []string{"domain-1"}, "")
+	case r.Method == "GET" && path == "/v2/routes":
+		if strings.Contains(r.URL.RawQuery, "host%3Amyapp") {
+			cc.page(w, []string{"route-1"}, "")
+			return
+		}
+		cc.page(w, nil, "")
+	case r.Method == "POST" && path == "/v2/routes":
+		cc.nextRoute++
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"metadata": {"guid": "new-route-%d"}, "entity": {}}`, cc.nextRoute)
+	case r.Method == "PUT" && strings.HasPrefix(path, "/v2/routes/"):
+		parts := strings.Split(path, "/")
+		app := cc.apps[parts[5]]
+		app.Routes = append(app.Routes, parts[3])
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "{}")
+	case r.Method == "DELETE" && strings.HasPrefix(path, "/v2/routes/"):
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && path == "/v2/spaces/space-guid/service_instances":
+		resources := []map[string]interface{}{}
+		for _, name := range cc.services {
+			resources = append(resources, map[string]interface{}{"metadata": map[string]string{"guid": name}, "entity": map[string]string{"name": name}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"resources": resources})
+	case r.Method == "GET" && path == "/v2/organizations/org-guid/memory_usage":
+		fmt.Fprint(w, `{"memory_usage_in_mb": 768}`)
+	default:
+		cc.fail(w, http.StatusNotFound, 10000, "CF-NotFound", "Unknown request")
+	}
+}
+
+//listApps - the apps in the space, filtered by name and split into pages
+func (cc *standInCC) listApps(w http.ResponseWriter, r *http.Request) {
+	filter := strings.TrimPrefix(r.URL.Query().Get("q"), "name:")
+	names := []string{}
+	for _, app := range cc.apps {
+		if filter == "" || app.Name == filter {
+			names = append(names, app.Guid)
+		}
+	}
+	sort.Strings(names)
+
+	next := ""
+	if r.URL.Query().Get("page") == "" && len(names) > cc.pageSize {
+		names, next = names[:cc.pageSize], "/v2/spaces/space-guid/apps?page=2"
+	} else if r.URL.Query().Get("page") == "2" {
+		names = names[cc.pageSize:]
+	}
+	cc.page(w, names, next)
+}
+
+func (cc *standInCC) page(w http.ResponseWriter, guids []string, next string) {
+	resources := []map[string]interface{}{}
+	for _, guid := range guids {
+		entity := map[string]string{}
+		if app, ok := cc.apps[guid]; ok {
+			entity["name"] = app.Name
+		}
+		resources = append(resources, map[string]interface{}{"metadata": map[string]string{"guid": guid}, "entity": entity})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"next_url": next, "resources": resources})
+}
+
+func (cc *standInCC) summary(w http.ResponseWriter, app *standInApp) {
+	routes := []map[string]interface{}{}
+	for _, guid := range app.Routes {
+		routes = append(routes, map[string]interface{}{
+			"guid":   guid,
+			"host":   cc.routes[guid],
+			"domain": map[string]string{"guid": "domain-1", "name": "example.com"},
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"guid":              app.Guid,
+		"name":              app.Name,
+		"state":             app.State,
+		"instances":         app.Instances,
+		"running_instances": 1,
+		"memory":            app.Memory,
+		"disk_quota":        1024,
+		"environment_json":  app.Env,
+		"routes":            routes,
+		"services":          []map[string]string{{"guid": "db-guid", "name": "db"}},
+	})
+}
+
+func (cc *standInCC) fail(w http.ResponseWriter, status, code int, errorCode, description string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": code, "error_code": errorCode, "description": description})
+}
+
+func (cc *standInCC) app(name string) *standInApp {
+	cc.Lock()
+	defer cc.Unlock()
+	for _, app := range cc.apps {
+		if app.Name == name {
+			return app
+		}
+	}
+	return nil
+}
+
+var _ = Describe("CloudControllerRepo", func() {
+	var (
+		cliConn *fakes.FakeCliConnection
+		cc      *standInCC
+		server  *httptest.Server
+		repo    *CloudControllerRepo
+	)
+
+	BeforeEach(func() {
+		cc = newStandInCC()
+		server = httptest.NewServer(cc)
+
+		cliConn = &fakes.FakeCliConnection{}
+		cliConn.ApiEndpointReturns(server.URL, nil)
+		cliConn.AccessTokenReturns("bearer good", nil)
+		space := plugin_models.Space{}
+		space.Guid = "space-guid"
+		cliConn.GetCurrentSpaceReturns(space, nil)
+		repo = NewCloudControllerRepo(cliConn, NewApplicationRepo(cliConn))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("RenameApplication", func() {
+		It("renames the application", func() {
+			Ω(repo.RenameApplication("myapp", "myapp-venerable")).Should(Succeed())
+			Ω(cc.app("myapp-venerable")).ShouldNot(BeNil())
+			Ω(cc.app("myapp")).Should(BeNil())
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+
+		It("returns a not found error for an app which does not exist", func() {
+			err := repo.RenameApplication("missing", "gone")
+			Ω(err).Should(MatchError("app missing not found"))
+			Ω(err).Should(BeAssignableToTypeOf(&NotFoundError{}))
+		})
+	})
+
+	Describe("PushApplication", func() {
+		It("pushes with the cli", func() {
+			Ω(repo.PushApplication([]string{"push", "myapp"})).Should(Succeed())
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"push", "myapp"}))
+		})
+	})
+
+	Describe("DeleteApplication", func() {
+		It("deletes the application", func() {
+			Ω(repo.DeleteApplication("other")).Should(Succeed())
+			Ω(cc.app("other")).Should(BeNil())
+		})
+
+		It("deletes the application's service bindings and route mappings with it", func() {
+			Ω(repo.DeleteApplication("other")).Should(Succeed())
+			Ω(cc.requests).Should(ContainElement("DELETE /v2/apps/app-2?recursive=true"))
+		})
+
+		It("succeeds when the application is already gone", func() {
+			Ω(repo.DeleteApplication("missing")).Should(Succeed())
+		})
+	})
+
+	Describe("SetEnv", func() {
+		It("sets the environment variable, keeping the rest", func() {
+			Ω(repo.SetEnv("myapp", "NAME", "value")).Should(Succeed())
+			Ω(cc.app("myapp").Env).Should(Equal(map[string]interface{}{"LOG_LEVEL": "debug", "NAME": "value"}))
+		})
+
+		It("unsets the environment variable when the value is empty", func() {
+			Ω(repo.SetEnv("myapp", "LOG_LEVEL", "")).Should(Succeed())
+			Ω(cc.app("myapp").Env).Should(BeEmpty())
+		})
+	})
+
+	Describe("ListApplicationsWithOutput", func() {
+		It("lists every app in the space across pages", func() {
+			cc.pageSize = 1
+			names, err := repo.ListApplicationsWithOutput()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(names).Should(ConsistOf("myapp", "other"))
+		})
+	})
+
+	Describe("GetApplication", func() {
+		It("returns the app in the shape the cli gives it", func() {
+			app, err := repo.GetApplication("myapp")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.Guid).Should(Equal("app-1"))
+			Ω(app.State).Should(Equal("started"))
+			Ω(app.InstanceCount).Should(Equal(2))
+			Ω(app.RunningInstances).Should(Equal(1))
+			Ω(app.Memory).Should(Equal(int64(256)))
+			Ω(app.DiskQuota).Should(Equal(int64(1024)))
+			Ω(app.EnvironmentVars).Should(Equal(map[string]interface{}{"LOG_LEVEL": "debug"}))
+			Ω(app.Routes).Should(Equal([]plugin_models.GetApp_RouteSummary{{
+				Guid:   "route-1",
+				Host:   "myapp",
+				Domain: plugin_models.GetApp_DomainFields{Guid: "domain-1", Name: "example.com"},
+			}}))
+			Ω(app.Services).Should(Equal([]plugin_models.GetApp_ServiceSummary{{Guid: "db-guid", Name: "db"}}))
+		})
+
+		It("returns the instances in index order", func() {
+			app, err := repo.GetApplication("myapp")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.Instances).Should(HaveLen(2))
+			Ω(app.Instances[0].State).Should(Equal("crashed"))
+			Ω(app.Instances[1].State).Should(Equal("running"))
+			Ω(app.Instances[1].Since).Should(Equal(time.Unix(1500000100, 5e8)))
+		})
+
+		It("does not ask for the instances of a stopped app", func() {
+			app, err := repo.GetApplication("other")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.Instances).Should(BeEmpty())
+			Ω(cc.requests).ShouldNot(ContainElement("GET /v2/apps/app-2/instances"))
+		})
+	})
+
+	Describe("MapRoute", func() {
+		It("maps an existing route", func() {
+			Ω(repo.MapRoute("other", "example.com", "myapp")).Should(Succeed())
+			Ω(cc.app("other").Routes).Should(Equal([]string{"route-1"}))
+		})
+
+		It("creates a route which does not exist", func() {
+			Ω(repo.MapRoute("other", "example.com", "other-green")).Should(Succeed())
+			Ω(cc.app("other").Routes).Should(Equal([]string{"new-route-1"}))
+			Ω(cc.requests).Should(ContainElement("POST /v2/routes"))
+		})
+	})
+
+	Describe("UnmapRoute", func() {
+		It("unmaps the route from the app", func() {
+			Ω(repo.UnmapRoute("myapp", "example.com", "myapp")).Should(Succeed())
+			Ω(cc.requests).Should(ContainElement("DELETE /v2/routes/route-1/apps/app-1"))
+		})
+	})
+
+	Describe("DeleteRoute", func() {
+		It("deletes the route", func() {
+			Ω(repo.DeleteRoute("example.com", "myapp")).Should(Succeed())
+			Ω(cc.requests).Should(ContainElement("DELETE /v2/routes/route-1"))
+		})
+
+		It("returns a not found error for a route which does not exist", func() {
+			Ω(repo.DeleteRoute("example.com", "missing")).Should(MatchError("route missing.example.com not found"))
+		})
+	})
+
+	Describe("ScaleApplication, StartApplication and StopApplication", func() {
+		It("update the app", func() {
+			Ω(repo.ScaleApplication("myapp", 4)).Should(Succeed())
+			Ω(repo.StopApplication("myapp")).Should(Succeed())
+			Ω(cc.app("myapp").Instances).Should(Equal(4))
+			Ω(cc.app("myapp").State).Should(Equal("STOPPED"))
+
+			Ω(repo.StartApplication("myapp")).Should(Succeed())
+			Ω(cc.app("myapp").State).Should(Equal("STARTED"))
+		})
+	})
+
+	Describe("ListServices", func() {
+		It("lists the service instances in the space", func() {
+			Ω(repo.ListServices()).Should(Equal([]string{"db", "cache"}))
+		})
+	})
+
+	Describe("GetOrgMemory", func() {
+		It("returns the org's quota and usage", func() {
+			org := plugin_models.Organization{}
+			org.Guid = "org-guid"
+			org.QuotaDefinition.MemoryLimit = 1024
+			cliConn.GetCurrentOrgReturns(org, nil)
+
+			limit, usage, err := repo.GetOrgMemory()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(limit).Should(Equal(int64(1024)))
+			Ω(usage).Should(Equal(int64(768)))
+		})
+	})
+
+	Describe("errors", func() {
+		It("returns the cloud controller's error", func() {
+			cc.failWith = http.StatusServiceUnavailable
+			err := repo.StopApplication("myapp")
+
+			apiErr, ok := err.(*APIError)
+			Ω(ok).Should(BeTrue())
+			Ω(apiErr.StatusCode).Should(Equal(http.StatusServiceUnavailable))
+			Ω(apiErr.ErrorCode).Should(Equal("CF-ServiceUnavailable"))
+			Ω(apiErr.Temporary()).Should(BeTrue())
+			Ω(err.Error()).Should(ContainSubstring("status code: 503, try again later (CF-ServiceUnavailable)"))
+		})
+
+		It("refreshes an expired access token from the cli once", func() {
+			tokens := []string{"bearer expired", "bearer good"}
+			cliConn.AccessTokenStub = func() (string, error) {
+				token := tokens[0]
+				if len(tokens) > 1 {
+					tokens = tokens[1:]
+				}
+				return token, nil
+			}
+
+			Ω(repo.ListServices()).Should(HaveLen(2))
+			Ω(repo.ListServices()).Should(HaveLen(2))
+			Ω(cliConn.AccessTokenCallCount()).Should(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,593 @@
+package fakes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/application_repo"
+)
+
+//FakeApp - an app as the fake foundation keeps it
+type FakeApp struct {
+	Guid      string
+	Name      string
+	Started   bool
+	Instances int
+	//Crashed - how many of the instances have crashed
+	Crashed  int
+	Memory   int64
+	Disk     int64
+	Env      map[string]string
+	Routes   []string
+	Services []string
+	//PushedWith - the arguments of the cf push which last pushed the app
+	PushedWith []string
+}
+
+//FakeFoundation - an in-memory foundation which applies the changes made
+//to it, so tests can check where a deployment left the apps. Each failure
+//set with FailOn is returned instead of making the change
+type FakeFoundation struct {
+	//Domain - the domain of the default route given to pushed apps
+	Domain string
+	//Uptime - how long instances have been running when they are looked at,
+	//so health checks do not have to wait
+	Uptime time.Duration
+	//MemoryLimit - the org's memory quota in megabytes, zero for none
+	MemoryLimit int64
+	//Org and Space - the names of the org and space being targeted
+	Org   string
+	Space string
+
+	mu        sync.Mutex
+	apps      map[string]*FakeApp
+	routes    map[string]bool
+	services  []string
+	crashing  map[string]int
+	failures  []failure
+	afterCall map[string]func(name string)
+	calls     []string
+	nextGuid  int
+	loggedOut bool
+}
+
+//failure - an error to return from calls to method for an app
+type failure struct {
+	method string
+	name   string
+	err    error
+	times  int
+}
+
+var _ application_repo.Repo = &FakeFoundation{}
+
+//NewFakeFoundation - constructor function for an empty foundation
+func NewFakeFoundation() *FakeFoundation {
+	return &FakeFoundation{
+		Domain:    "example.com",
+		Uptime:    time.Hour,
+		Org:       "my-org",
+		Space:     "my-space",
+		apps:      map[string]*FakeApp{},
+		routes:    map[string]bool{},
+		crashing:  map[string]int{},
+		afterCall: map[string]func(string){},
+	}
+}
+
+//AddApp - put a running app on the foundation, with its default route when
+//it has no routes
+func (f *FakeFoundation) AddApp(app FakeApp) *FakeApp {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if app.Instances == 0 {
+		app.Instances = 1
+	}
+	if app.Routes == nil {
+		app.Routes = []string{app.Name + "." + f.Domain}
+	}
+	if app.Guid == "" {
+		app.Guid = f.guid()
+	}
+	app.Started = true
+	for _, route := range app.Routes {
+		f.routes[route] = true
+	}
+
+	f.apps[app.Name] = &app
+	return &app
+}
+
+//AddServices - create service instances in the space
+func (f *FakeFoundation) AddServices(names ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services = append(f.services, names...)
+}
+
+//App - the app with the given name, nil when there is none
+func (f *FakeFoundation) App(name string) *FakeApp {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.apps[name]
+}
+
+//AppNames - the names of every app, sorted
+func (f *FakeFoundation) AppNames() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.appNames()
+}
+
+//HasRoute - whether the route host.domain exists
+func (f *FakeFoundation) HasRoute(route string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.routes[route]
+}
+
+//Calls - every call made of the foundation, e.g. "RenameApplication myapp myapp-venerable"
+func (f *FakeFoundation) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+//FailOn - make calls to method which name the app or route fail with err,
+//an empty name matches every call. times is how many calls fail, zero for all
+func (f *FakeFoundation) FailOn(method, name string, err error, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, failure{method: method, name: name, err: err, times: times})
+}
+
+//AfterCall - run hook once every call to method has been made, before it
+//returns, e.g. to interrupt a deployment while a push is in flight
+func (f *FakeFoundation) AfterCall(method string, hook func(name string)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.afterCall[method] = hook
+}
+
+//CrashOnStart - make the given number of instances crash whenever the app
+//with this name is pushed or started
+func (f *FakeFoundation) CrashOnStart(name string, instances int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.crashing[name] = instances
+}
+
+//LogOut - make IsLoggedIn report the cli is not logged in
+func (f *FakeFoundation) LogOut() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.loggedOut = true
+}
+
+//RenameApplication - rename the app, which must exist and whose new name must be free
+func (f *FakeFoundation) RenameApplication(oldName, newName string) error {
+	return f.call("RenameApplication", oldName, []string{newName}, func() error {
+		app, err := f.app(oldName)
+		if err != nil {
+			return err
+		}
+		if _, taken := f.apps[newName]; taken {
+			return fmt.Errorf("App with the name %s already exists", newName)
+		}
+
+		delete(f.apps, oldName)
+		app.Name = newName
+		f.apps[newName] = app
+		return nil
+	})
+}
+
+//PushApplication - create or update the app named in args, which are the
+//arguments of cf push. -i, -m, -k, -n, -d, --no-route and --no-start are understood
+func (f *FakeFoundation) PushApplication(args []string) error {
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	return f.call("PushApplication", name, args[2:], func() error {
+		if name == "" || strings.HasPrefix(name, "-") {
+			return fmt.Errorf("the fake foundation needs the app name given to cf push")
+		}
+
+		app, exists := f.apps[name]
+		if !exists {
+			app = &FakeApp{Guid: f.guid(), Name: name, Instances: 1, Memory: 1024, Disk: 1024}
+		}
+
+		host, domain, route, start := name, f.Domain, true, true
+		for i := 2; i < len(args); i++ {
+			value := ""
+			if i+1 < len(args) {
+				value = args[i+1]
+			}
+
+			var err error
+			switch args[i] {
+			case "-i":
+				app.Instances, err = strconv.Atoi(value)
+				i++
+			case "-m":
+				app.Memory, err = megabytes(value)
+				i++
+			case "-k":
+				app.Disk, err = megabytes(value)
+				i++
+			case "-n", "--hostname":
+				host = value
+				i++
+			case "-d":
+				domain = value
+				i++
+			case "--no-route":
+				route = false
+			case "--no-start":
+				start = false
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s: %s", args[i-1], value, err)
+			}
+		}
+
+		if route && !exists {
+			f.addRoute(app, host+"."+domain)
+		}
+		app.PushedWith = append([]string{}, args...)
+		f.apps[name] = app
+		if start {
+			f.start(app)
+		}
+		return nil
+	})
+}
+
+//DeleteApplication - delete the app, like cf delete -f there is no error
+//when it does not exist
+func (f *FakeFoundation) DeleteApplication(appName string) error {
+	return f.call("DeleteApplication", appName, nil, func() error {
+		delete(f.apps, appName)
+		return nil
+	})
+}
+
+//ListApplications - list the apps
+func (f *FakeFoundation) ListApplications() error {
+	return f.call("ListApplications", "", nil, func() error {
+		return nil
+	})
+}
+
+//ListApplicationsWithOutput - the names of every app
+func (f *FakeFoundation) ListApplicationsWithOutput() (names []string, err error) {
+	err = f.call("ListApplicationsWithOutput", "", nil, func() error {
+		names = f.appNames()
+		return nil
+	})
+	return
+}
+
+//GetApplication - the app as the cli would describe it
+func (f *FakeFoundation) GetApplication(appName string) (model plugin_models.GetAppModel, err error) {
+	err = f.call("GetApplication", appName, nil, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		model = f.model(app)
+		return nil
+	})
+	return
+}
+
+//MapRoute - map host.domain to the app, creating the route if need be
+func (f *FakeFoundation) MapRoute(appName, domain, host string) error {
+	return f.call("MapRoute", appName, []string{routeName(domain, host)}, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		f.addRoute(app, routeName(domain, host))
+		return nil
+	})
+}
+
+//UnmapRoute - unmap host.domain from the app, leaving the route in place
+func (f *FakeFoundation) UnmapRoute(appName, domain, host string) error {
+	return f.call("UnmapRoute", appName, []string{routeName(domain, host)}, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		app.Routes = without(app.Routes, routeName(domain, host))
+		return nil
+	})
+}
+
+//DeleteRoute - delete host.domain, unmapping it from every app
+func (f *FakeFoundation) DeleteRoute(domain, host string) error {
+	route := routeName(domain, host)
+	return f.call("DeleteRoute", route, nil, func() error {
+		delete(f.routes, route)
+		for _, app := range f.apps {
+			app.Routes = without(app.Routes, route)
+		}
+		return nil
+	})
+}
+
+//ScaleApplication - set the number of instances of the app
+func (f *FakeFoundation) ScaleApplication(appName string, instances int) error {
+	return f.call("ScaleApplication", appName, []string{strconv.Itoa(instances)}, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		app.Instances = instances
+		return nil
+	})
+}
+
+//StartApplication - start the app
+func (f *FakeFoundation) StartApplication(appName string) error {
+	return f.call("StartApplication", appName, nil, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		f.start(app)
+		return nil
+	})
+}
+
+//StopApplication - stop the app
+func (f *FakeFoundation) StopApplication(appName string) error {
+	return f.call("StopApplication", appName, nil, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		app.Started = false
+		return nil
+	})
+}
+
+//SetEnv - set or, when value is empty, unset an environment variable of the app
+func (f *FakeFoundation) SetEnv(appName, name, value string) error {
+	return f.call("SetEnv", appName, []string{name, value}, func() error {
+		app, err := f.app(appName)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			delete(app.Env, name)
+			return nil
+		}
+		if app.Env == nil {
+			app.Env = map[string]string{}
+		}
+		app.Env[name] = value
+		return nil
+	})
+}
+
+//ApiEndpoint - a made up api endpoint
+func (f *FakeFoundation) ApiEndpoint() (endpoint string, err error) {
+	err = f.call("ApiEndpoint", "", nil, func() error {
+		endpoint = "https://api." + f.Domain
+		return nil
+	})
+	return
+}
+
+//Target - the Org and Space of the foundation
+func (f *FakeFoundation) Target() (org, space string, err error) {
+	err = f.call("Target", "", nil, func() error {
+		org, space = f.Org, f.Space
+		return nil
+	})
+	return
+}
+
+//IsLoggedIn - true until LogOut is called
+func (f *FakeFoundation) IsLoggedIn() (loggedIn bool, err error) {
+	err = f.call("IsLoggedIn", "", nil, func() error {
+		loggedIn = !f.loggedOut
+		return nil
+	})
+	return
+}
+
+//ListServices - the service instances added with AddServices
+func (f *FakeFoundation) ListServices() (names []string, err error) {
+	err = f.call("ListServices", "", nil, func() error {
+		names = append([]string{}, f.services...)
+		return nil
+	})
+	return
+}
+
+//GetOrgMemory - MemoryLimit and the memory of every started instance
+func (f *FakeFoundation) GetOrgMemory() (limit, usage int64, err error) {
+	err = f.call("GetOrgMemory", "", nil, func() error {
+		limit = f.MemoryLimit
+		for _, app := range f.apps {
+			if app.Started {
+				usage += app.Memory * int64(app.Instances)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+//call - record the call, then return the failure set for it or make the change
+func (f *FakeFoundation) call(method, name string, args []string, change func() error) error {
+	err := f.apply(method, name, args, change)
+
+	f.mu.Lock()
+	hook := f.afterCall[method]
+	f.mu.Unlock()
+	if hook != nil {
+		hook(name)
+	}
+	return err
+}
+
+//apply - the part of call made while holding the lock
+func (f *FakeFoundation) apply(method, name string, args []string, change func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, strings.TrimSpace(strings.Join(append([]string{method, name}, args...), " ")))
+	for i := range f.failures {
+		failure := &f.failures[i]
+		if failure.method != method || (failure.name != "" && failure.name != name) || failure.times < 0 {
+			continue
+		}
+		if failure.times > 0 {
+			if failure.times--; failure.times == 0 {
+				failure.times = -1
+			}
+		}
+		return failure.err
+	}
+	return change()
+}
+
+func (f *FakeFoundation) app(name string) (*FakeApp, error) {
+	app, ok := f.apps[name]
+	if !ok {
+		return nil, fmt.Errorf("App %s not found", name)
+	}
+	return app, nil
+}
+
+func (f *FakeFoundation) appNames() []string {
+	names := []string{}
+	for name := range f.apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *FakeFoundation) start(app *FakeApp) {
+	app.Started = true
+	app.Crashed = f.crashing[app.Name]
+}
+
+func (f *FakeFoundation) addRoute(app *FakeApp, route string) {
+	f.routes[route] = true
+	for _, mapped := range app.Routes {
+		if mapped == route {
+			return
+		}
+	}
+	app.Routes = append(app.Routes, route)
+}
+
+func (f *FakeFoundation) guid() string {
+	f.nextGuid++
+	return fmt.Sprintf("guid-%d", f.nextGuid)
+}
+
+//model - the app in the shape the cli gives it
+func (f *FakeFoundation) model(app *FakeApp) plugin_models.GetAppModel {
+	model := plugin_models.GetAppModel{
+		Guid:          app.Guid,
+		Name:          app.Name,
+		State:         "stopped",
+		Memory:        app.Memory,
+		DiskQuota:     app.Disk,
+		InstanceCount: app.Instances,
+	}
+
+	if len(app.Env) > 0 {
+		model.EnvironmentVars = map[string]interface{}{}
+		for key, value := range app.Env {
+			model.EnvironmentVars[key] = value
+		}
+	}
+	for _, route := range app.Routes {
+		host, domain := splitRoute(route, f.Domain)
+		model.Routes = append(model.Routes, plugin_models.GetApp_RouteSummary{
+			Host:   host,
+			Domain: plugin_models.GetApp_DomainFields{Name: domain},
+		})
+	}
+	for _, service := range app.Services {
+		model.Services = append(model.Services, plugin_models.GetApp_ServiceSummary{Name: service})
+	}
+
+	if app.Started {
+		model.State = "started"
+		since := time.Now().Add(-f.Uptime)
+		for i := 0; i < app.Instances; i++ {
+			state := "running"
+			if i < app.Crashed {
+				state = "crashed"
+			} else {
+				model.RunningInstances++
+			}
+			model.Instances = append(model.Instances, plugin_models.GetApp_AppInstanceFields{State: state, Since: since})
+		}
+	}
+	return model
+}
+
+func routeName(domain, host string) string {
+	if host == "" {
+		return domain
+	}
+	return host + "." + domain
+}
+
+//splitRoute - the host and domain of route, which is on domain when it can be
+func splitRoute(route, domain string) (string, string) {
+	if route == domain {
+		return "", domain
+	}
+	if strings.HasSuffix(route, "."+domain) {
+		return strings.TrimSuffix(route, "."+domain), domain
+	}
+	parts := strings.SplitN(route, ".", 2)
+	if len(parts) == 1 {
+		return "", route
+	}
+	return parts[0], parts[1]
+}
+
+func without(routes []string, route string) (left []string) {
+	for _, mapped := range routes {
+		if mapped != route {
+			left = append(left, mapped)
+		}
+	}
+	return
+}
+
+//megabytes - a size such as 512M or 1G in megabytes
+func megabytes(size string) (int64, error) {
+	upper := strings.ToUpper(size)
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(upper, "G"), strings.HasSuffix(upper, "GB"):
+		multiplier = 1024
+	case strings.HasSuffix(upper, "M"), strings.HasSuffix(upper, "MB"):
+	default:
+		return 0, fmt.Errorf("unknown size %q", size)
+	}
+	n, err := strconv.ParseInt(strings.TrimRight(upper, "GMB"), 10, 64)
+	return n * multiplier, err
+}
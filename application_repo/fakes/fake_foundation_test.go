@@ -0,0 +1,78 @@
+package fakes_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot/application_repo/fakes"
+)
+
+var _ = Describe("FakeFoundation", func() {
+	var foundation *FakeFoundation
+
+	BeforeEach(func() {
+		foundation = NewFakeFoundation()
+		foundation.AddApp(FakeApp{Name: "myapp"})
+	})
+
+	Describe("PushApplication", func() {
+		It("creates a started app on its default route", func() {
+			err := foundation.PushApplication([]string{"push", "other", "-i", "3", "-m", "1G"})
+
+			Ω(err).ShouldNot(HaveOccurred())
+			app := foundation.App("other")
+			Ω(app.Started).Should(BeTrue())
+			Ω(app.Instances).Should(Equal(3))
+			Ω(app.Memory).Should(Equal(int64(1024)))
+			Ω(app.Routes).Should(Equal([]string{"other.example.com"}))
+		})
+
+		It("leaves the app stopped and unrouted when asked to", func() {
+			foundation.PushApplication([]string{"push", "other", "--no-start", "--no-route"})
+
+			Ω(foundation.App("other").Started).Should(BeFalse())
+			Ω(foundation.App("other").Routes).Should(BeEmpty())
+		})
+	})
+
+	Describe("RenameApplication", func() {
+		It("refuses a name which is taken", func() {
+			foundation.AddApp(FakeApp{Name: "myapp-venerable"})
+
+			Ω(foundation.RenameApplication("myapp", "myapp-venerable")).ShouldNot(Succeed())
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp", "myapp-venerable"}))
+		})
+
+		It("keeps the app's routes", func() {
+			Ω(foundation.RenameApplication("myapp", "myapp-venerable")).Should(Succeed())
+			Ω(foundation.App("myapp-venerable").Routes).Should(Equal([]string{"myapp.example.com"}))
+		})
+	})
+
+	Describe("GetApplication", func() {
+		It("reports crashed instances", func() {
+			foundation.CrashOnStart("myapp", 1)
+			foundation.ScaleApplication("myapp", 2)
+			foundation.StartApplication("myapp")
+
+			app, err := foundation.GetApplication("myapp")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(app.InstanceCount).Should(Equal(2))
+			Ω(app.RunningInstances).Should(Equal(1))
+		})
+	})
+
+	Describe("FailOn", func() {
+		It("fails the given number of calls without making the change", func() {
+			foundation.FailOn("DeleteApplication", "myapp", errors.New("boom"), 1)
+
+			Ω(foundation.DeleteApplication("myapp")).Should(MatchError("boom"))
+			Ω(foundation.App("myapp")).ShouldNot(BeNil())
+			Ω(foundation.DeleteApplication("myapp")).Should(Succeed())
+			Ω(foundation.App("myapp")).Should(BeNil())
+			Ω(foundation.Calls()).Should(Equal([]string{"DeleteApplication myapp", "DeleteApplication myapp"}))
+		})
+	})
+})
@@ -0,0 +1,31 @@
+package application_repo
+
+import "github.com/cloudfoundry/cli/plugin/models"
+
+//Repo - the operations a deployment makes on the foundation, implemented by
+//ApplicationRepo through the cli and CloudControllerRepo through the api
+type Repo interface {
+	RenameApplication(oldName, newName string) error
+	PushApplication(args []string) error
+	DeleteApplication(appName string) error
+	ListApplications() error
+	ListApplicationsWithOutput() ([]string, error)
+	GetApplication(appName string) (plugin_models.GetAppModel, error)
+	MapRoute(appName, domain, host string) error
+	UnmapRoute(appName, domain, host string) error
+	DeleteRoute(domain, host string) error
+	ScaleApplication(appName string, instances int) error
+	StartApplication(appName string) error
+	StopApplication(appName string) error
+	SetEnv(appName, name, value string) error
+	ApiEndpoint() (string, error)
+	Target() (org, space string, err error)
+	IsLoggedIn() (bool, error)
+	ListServices() ([]string, error)
+	GetOrgMemory() (limit, usage int64, err error)
+}
+
+var (
+	_ Repo = &ApplicationRepo{}
+	_ Repo = &CloudControllerRepo{}
+)
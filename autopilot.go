@@ -1,106 +1,454 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cloudfoundry/cli/plugin"
 	"github.com/xchapter7x/autopilot/application_repo"
+	"github.com/xchapter7x/autopilot/journal"
+	"github.com/xchapter7x/autopilot/manifest"
 	"github.com/xchapter7x/autopilot/rewind"
 )
 
 //AutopilotPlugin - the object implementing the plugin for zdd
 type AutopilotPlugin struct {
-	appRepo          *application_repo.ApplicationRepo
+	appRepo          application_repo.Repo
+	journal          *journal.Journal
+	options          options
+	report           *report
+	manifest         *manifest.Manifest
+	summary          *runSummary
+	services         []string
+	manifestProblems []string
+	preflighted      bool
 	appName          string
 	venerableAppName string
 }
 
+//names of the steps recorded in the deployment journal
+const (
+	renameStep  = "rename"
+	pushStep    = "push"
+	deleteStep  = "delete"
+	discardStep = "discard"
+	restoreStep = "restore"
+	recoverStep = "recover"
+	reviveStep  = "revive"
+)
+
 func main() {
 	plugin.Start(&AutopilotPlugin{})
 }
 
+//cancelSignals - signals which abandon the deployment and roll it back
+var cancelSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+//abandonGrace - how long a step which timed out or was cancelled is given to
+//finish before its changes are undone, when the rollback does not go through
+//the cli. The cli cannot run a command while another is in flight, so it is
+//always waited for
+var abandonGrace = 10 * time.Second
+
 //Run - required command of a plugin (entry point)
 func (plugin AutopilotPlugin) Run(cliConnection plugin.CliConnection, args []string) {
+	fatalIf(plugin.run(cliConnection, args))
+}
 
-	var err error
-	plugin.appRepo = application_repo.NewApplicationRepo(cliConnection)
-
+func (plugin AutopilotPlugin) run(cliConnection plugin.CliConnection, args []string) (err error) {
 	if args[0] == "push-zdd" && len(args) == 1 {
-		err = plugin.appRepo.PushApplication([]string{"push", "-h"})
-		fatalIf(err)
-		return
+		return newRepo(cliConnection, options{}).PushApplication([]string{"push", "-h"})
+	}
+
+	plugin.options, args, err = parseOptions(args)
+	if err != nil {
+		return err
+	}
+	plugin.appRepo = newRepo(cliConnection, plugin.options)
+
+	switch args[0] {
+	case "zdd-recover":
+		return plugin.recoverDeployment(args)
+	case "zdd-cleanup":
+		return plugin.cleanupDeployment(args)
+	case "zdd-rollback":
+		return plugin.rollbackDeployment(args)
+	case "zdd-diff":
+		return plugin.diffDeployment(args)
+	}
+
+	plugin.summary = &runSummary{started: time.Now()}
+	if plugin.options.output == outputJSON {
+		defer func() {
+			err = plugin.writeSummary(err)
+		}()
 	}
 
 	appName, argList := ParseArgs(args)
-	plugin.appName = appName
-	plugin.venerableAppName = appName + "-venerable"
+	m, pushArgs, cleanup, err := loadManifest(argList, plugin.options)
+	defer cleanup()
+	if err != nil {
+		if appName == "" {
+			return &preflightError{problems: []string{err.Error()}}
+		}
+		//reported along with whatever else pre-flight finds
+		plugin.manifestProblems = []string{err.Error()}
+		pushArgs = argList
+	}
+	plugin.manifest = m
+	argList = pushArgs
+
+	if appName == "" {
+		return plugin.pushManifest(argList)
+	}
+
+	if plugin.manifest != nil {
+		if _, ok := plugin.manifest.Application(appName); !ok {
+			plugin.manifestProblems = []string{fmt.Sprintf("%s is not one of the applications in manifest %s", appName, manifestPath(args))}
+		}
+	}
+
+	if err = plugin.deployApp(appName, argList); err != nil || plugin.options.dryRun {
+		return err
+	}
+	return plugin.appRepo.ListApplications()
+}
+
+//deployApp - replace appName with a new version pushed with argList, noting
+//how it went for the summary
+func (plugin AutopilotPlugin) deployApp(appName string, argList []string) error {
+	started := time.Now()
+	err := plugin.deploy(appName, argList)
+	plugin.summary.apps = append(plugin.summary.apps, appOutcome{
+		appName:  appName,
+		err:      err,
+		started:  started,
+		finished: time.Now(),
+	})
+	return err
+}
+
+func (plugin AutopilotPlugin) deploy(appName string, argList []string) error {
+	plugin.setAppName(appName)
+	plugin.report = &report{}
+
+	if !plugin.preflighted {
+		if err := plugin.preflight(argList); err != nil {
+			return err
+		}
+	}
+
+	argList, cleanup, err := plugin.inheritFromCurrent(argList)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	actionList, err := plugin.getActions(argList)
+	if err != nil {
+		return err
+	}
+
+	if plugin.options.dryRun {
+		return plugin.newPlan(actionList).print(stdout, plugin.options.output)
+	}
+
+	err = plugin.execute(actionList, "Oh no. Something's gone wrong. I've tried to roll back but you should check to see if everything is OK.")
+	if err != nil {
+		return err
+	}
+
+	plugin.printf("\nA new version of your application has successfully been pushed!\n\n")
+	return nil
+}
+
+//execute - run actionList as a journaled deployment, which is rolled back if
+//one of its actions fails or it is interrupted
+func (plugin AutopilotPlugin) execute(actionList []rewind.Action, rewindFailureMessage string) error {
+	if err := plugin.journal.Record(plugin.entry(journal.DeploymentStep, journal.Started, nil)); err != nil {
+		return fmt.Errorf("could not write to the deployment journal: %s", err)
+	}
 
 	actions := rewind.Actions{
-		Actions:              plugin.getActions(argList),
-		RewindFailureMessage: "Oh no. Something's gone wrong. I've tried to roll back but you should check to see if everything is OK.",
+		Actions:              actionList,
+		RewindFailureMessage: rewindFailureMessage,
+		Observer:             plugin.observer(len(actionList)),
+		Timeout:              plugin.options.deployTimeout,
+		Grace:                abandonGrace,
+	}
+	if plugin.options.client == clientCLI {
+		actions.Grace = -1
+	}
+
+	ctx, stop := plugin.trapSignals()
+	defer stop()
+
+	err := actions.ExecuteContext(ctx)
+	plugin.report.print(plugin.output())
+	plugin.record(journal.DeploymentStep, journal.Succeeded, journal.Failed, err)
+	return err
+}
+
+//observer - reports the progress of a deployment of total steps, as json
+//events as well when asked for them
+func (plugin AutopilotPlugin) observer(total int) rewind.Observer {
+	console := consoleObserver{plugin: plugin, total: total}
+	if plugin.options.output == outputJSON {
+		return jsonObserver{console}
+	}
+	return console
+}
+
+//trapSignals - returns a context which is cancelled when one of the
+//cancelSignals is received, and a func to stop listening for them
+func (plugin AutopilotPlugin) trapSignals() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, cancelSignals...)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			plugin.printf("\nreceived %s, rolling back...\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+//getActions - the steps which replace the app with a new version, using the
+//strategy asked for when there is a version to replace
+func (plugin AutopilotPlugin) getActions(argList []string) ([]rewind.Action, error) {
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	if plugin.services, err = plugin.currentServices(apps); err != nil {
+		return nil, err
+	}
+
+	var actionList []rewind.Action
+	switch {
+	case !contains(apps, plugin.appName) && plugin.staleVenerable(apps) && plugin.options.onStaleVenerable == staleReuse:
+		actionList = plugin.getReuseActions(argList)
+	case !contains(apps, plugin.appName):
+		actionList = []rewind.Action{plugin.journaled(pushStep, plugin.getPushAction(argList))}
+	case plugin.options.strategy == blueGreenStrategy:
+		actionList, err = plugin.getBlueGreenActions(argList)
+	case plugin.options.strategy == canaryStrategy:
+		actionList, err = plugin.getCanaryActions(argList)
+	default:
+		actionList = plugin.getManifestActions(argList)
 	}
 
-	err = actions.Execute()
-	fatalIf(err)
+	cleanupActions := append(plugin.getBakedCleanupActions(apps), plugin.getStaleVenerableActions(apps)...)
+	return append(cleanupActions, actionList...), err
+}
 
-	fmt.Printf("\nA new version of your application has successfully been pushed!\n\n")
+func (plugin AutopilotPlugin) getManifestActions(argList []string) []rewind.Action {
+	plugin.printf("\n%s was found, using zero-downtime-deployment\n\n", plugin.appName)
+	pushAction := plugin.getPushAction(argList)
+	plugin.addReversePrevious(&pushAction)
 
-	err = plugin.appRepo.ListApplications()
-	fatalIf(err)
+	actionList := []rewind.Action{
+		plugin.journaled(renameStep, plugin.getRenameAction()),
+		plugin.journaled(pushStep, pushAction),
+	}
+	actionList = append(actionList, plugin.getVerificationActions(plugin.appName, plugin.venerableAppName, argList)...)
+	return append(actionList, plugin.getRetireAction())
 }
 
-func (plugin AutopilotPlugin) getActions(argList []string) (actionList []rewind.Action) {
-	actionList = []rewind.Action{plugin.getPushAction(argList)}
+func (plugin *AutopilotPlugin) setAppName(appName string) {
+	plugin.appName = appName
+	plugin.venerableAppName = appName + "-venerable"
+	plugin.journal = journal.New(plugin.journalDir(), appName)
+}
 
-	if appExists(getAppList(plugin.appRepo), plugin.appName) {
-		fmt.Printf("\n%s was found, using zero-downtime-deployment\n\n", plugin.appName)
-		actionList = []rewind.Action{
-			plugin.getRenameAction(),
-			plugin.getPushAction(argList),
-			plugin.getDeleteAction(),
+//journalDir - where the journals of the targeted api endpoint, org and space
+//are kept, falling back to the shared directory when the target is unknown
+func (plugin AutopilotPlugin) journalDir() string {
+	endpoint, err := plugin.appRepo.ApiEndpoint()
+	if err != nil {
+		return journal.DefaultDir()
+	}
+	org, space, err := plugin.appRepo.Target()
+	if err != nil {
+		return journal.DefaultDir()
+	}
+	return journal.TargetDir(journal.DefaultDir(), endpoint, org, space)
+}
+
+//journaled - wrap action so each of its transitions is written to the journal,
+//naming it after step, retrying it when it fails for a transient reason and
+//limiting how long it may take
+func (plugin AutopilotPlugin) journaled(step string, action rewind.Action) rewind.Action {
+	metadata := map[string]string{"app": plugin.appName}
+	for key, value := range action.Metadata {
+		metadata[key] = value
+	}
+
+	retry := action.Retry
+	if retry == nil {
+		retry = plugin.retryPolicy()
+	}
+
+	timeout := action.Timeout
+	if timeout == 0 {
+		timeout = plugin.options.stepTimeout
+	}
+
+	//an abandoned step must not write its outcome over the rollback's
+	var (
+		mu        sync.Mutex
+		abandoned bool
+	)
+	journaledAction := rewind.Action{
+		Name:        step,
+		Description: action.Description,
+		Metadata:    metadata,
+		Retry:       retry,
+		Timeout:     timeout,
+		Forward: func() error {
+			plugin.recordStep(step, action.Metadata, journal.Started, journal.Started, nil)
+			err := action.Forward()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !abandoned {
+				plugin.recordStep(step, action.Metadata, journal.Succeeded, journal.Failed, err)
+			}
+			return err
+		},
+		Abandoned: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			abandoned = true
+		},
+	}
+
+	if action.Reverse != nil {
+		journaledAction.Reverse = func() error {
+			err := action.Reverse()
+			plugin.record(step, journal.Reversed, journal.ReverseFailed, err)
+			return err
+		}
+	}
+
+	if action.ReversePrevious != nil {
+		journaledAction.ReversePrevious = func() error {
+			err := action.ReversePrevious()
+			plugin.record(step, journal.Reversed, journal.ReverseFailed, err)
+			return err
 		}
+	}
+	return journaledAction
+}
+
+//record - write the outcome of step to the journal, a journal which cannot be
+//written to is reported but does not stop the deployment
+func (plugin AutopilotPlugin) record(step, success, failure string, err error) {
+	plugin.recordStep(step, nil, success, failure, err)
+}
+
+//recordStep - write the outcome of step to the journal along with metadata
+func (plugin AutopilotPlugin) recordStep(step string, metadata map[string]string, success, failure string, err error) {
+	outcome := success
+	if err != nil {
+		outcome = failure
+	}
 
-		plugin.addReversePrevious(&actionList[1])
+	entry := plugin.entry(step, outcome, err)
+	entry.Metadata = metadata
+	if recordErr := plugin.journal.Record(entry); recordErr != nil {
+		plugin.printf("warning: could not write to the deployment journal: %s\n", recordErr)
+	}
+}
 
+func (plugin AutopilotPlugin) entry(step, outcome string, err error) journal.Entry {
+	entry := journal.Entry{
+		App:     plugin.appName,
+		Step:    step,
+		Outcome: outcome,
 	}
-	return
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
 }
 
 func (plugin AutopilotPlugin) getPushAction(argList []string) rewind.Action {
 	return rewind.Action{
+		Description: describePush(argList),
 		Forward: func() error {
 			return plugin.appRepo.PushApplication(argList)
 		},
+		Reverse: func() error {
+			return plugin.appRepo.DeleteApplication(plugin.appName)
+		},
 	}
 }
 
 func (plugin AutopilotPlugin) addReversePrevious(action *rewind.Action) {
 	action.ReversePrevious = func() error {
-		plugin.appRepo.DeleteApplication(plugin.appName)
-
-		return plugin.appRepo.RenameApplication(plugin.venerableAppName, plugin.appName)
+		return plugin.appRepo.DeleteApplication(plugin.appName)
 	}
 }
 
 func (plugin AutopilotPlugin) getRenameAction() rewind.Action {
 	return rewind.Action{
+		Description: fmt.Sprintf("rename %s to %s", plugin.appName, plugin.venerableAppName),
+		Retry:       noRetry,
 		Forward: func() error {
 			return plugin.appRepo.RenameApplication(plugin.appName, plugin.venerableAppName)
 		},
+		Reverse: func() error {
+			return plugin.appRepo.RenameApplication(plugin.venerableAppName, plugin.appName)
+		},
+		ReversePrevious: func() error {
+			return plugin.undoRename(plugin.appName, plugin.venerableAppName)
+		},
+	}
+}
+
+//undoRename - rename to back to from when a rename which was stopped or
+//reported an error went through after all
+func (plugin AutopilotPlugin) undoRename(from, to string) error {
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil || contains(apps, from) || !contains(apps, to) {
+		return err
 	}
+	return plugin.appRepo.RenameApplication(to, from)
 }
 
 func (plugin AutopilotPlugin) getDeleteAction() rewind.Action {
 	return rewind.Action{
+		Description: fmt.Sprintf("delete %s", plugin.venerableAppName),
 		Forward: func() error {
 			return plugin.appRepo.DeleteApplication(plugin.venerableAppName)
 		},
 	}
 }
 
+//describePush - the cf command a push action runs
+func describePush(argList []string) string {
+	return "cf " + strings.Join(argList, " ")
+}
+
 //GetMetadata - required command of plugin (returns meta data about plugin)
 func (AutopilotPlugin) GetMetadata() plugin.PluginMetadata {
 	return plugin.PluginMetadata{
@@ -109,40 +457,165 @@ func (AutopilotPlugin) GetMetadata() plugin.PluginMetadata {
 			{
 				Name:     "push-zdd",
 				HelpText: "Perform a zero-downtime push of an application over the top of an old one",
+				UsageDetails: plugin.Usage{
+					Usage: "cf push-zdd APP [--strategy manifest|blue-green|canary] [cf push flags]\n   cf push-zdd -f MANIFEST_PATH [--strategy manifest|blue-green|canary] [cf push flags]",
+					Options: map[string]string{
+						"-strategy":              "manifest (default) renames the old app and relies on the manifest's routes, blue-green pushes under a temporary name and route then remaps the old app's routes, canary shifts instances from the old app to the new one in steps",
+						"-canary-instances":      "Number of instances the new version starts with when using the canary strategy (default 1)",
+						"-steps":                 "Comma separated percentages of the old app's instances to move to the new version, ending at 100 (default 25,50,100)",
+						"-canary-pause":          "How long to wait between canary steps before checking the new version is healthy (default 30s)",
+						"-health-timeout":        "How long to wait for the new version to become healthy before rolling back (default 2m)",
+						"-health-window":         "How long every instance of the new version must have been running to be considered healthy (default 10s)",
+						"-smoke-url":             "Path requested from the new version's first route before the old app is retired, e.g. /health",
+						"-smoke-expect":          "HTTP status the smoke test must return (default 200)",
+						"-smoke-count":           "Number of consecutive smoke test requests which must pass (default 3)",
+						"-smoke-scheme":          "http or https (default https)",
+						"-smoke-cmd":             "Command run once the new version is healthy, a non-zero exit rolls the deployment back",
+						"-bake-time":             "Stop the old app and keep it for this long instead of deleting it, e.g. 10m",
+						"-on-stale-venerable":    "What to do with an APP-venerable left over from an earlier deployment: fail (default), delete it first, or reuse it as the version to replace when APP is missing",
+						"-inherit-from-current":  "Give the new version the instances, memory, disk quota, environment variables, services and routes of the app it replaces wherever the manifest leaves them out",
+						"-allow-service-removal": "A service the new version need not be bound to although the current version is, may be given more than once",
+						"-dry-run":               "Print the steps the deployment would take without changing anything",
+						"-output":                "text (default) or json, which writes the plan or each step of the deployment and a final summary to stdout as lines of json",
+						"-vars-file":             "File of values for the ((variables)) in the manifest, may be given more than once",
+						"-var":                   "Value for a ((variable)) in the manifest given as name=value, may be given more than once",
+						"-max-attempts":          "How many times to try a step which fails for a transient reason such as a dropped connection, 1 turns retries off (default 3)",
+						"-retry-backoff":         "How long to wait before the first retry, doubling for each one after (default 2s)",
+						"-step-timeout":          "The longest any one step, such as cf push or a health check, may take before the deployment is rolled back, e.g. 10m (default no limit)",
+						"-deploy-timeout":        "The longest the deployment may take before it is rolled back, e.g. 30m (default no limit)",
+						"-client":                "cli (default) runs cf commands, api talks to the cloud controller's api directly for everything but cf push",
+					},
+				},
+			},
+			{
+				Name:     "zdd-recover",
+				HelpText: "Finish or roll back a zero-downtime push which was interrupted, using its deployment journal",
+				UsageDetails: plugin.Usage{
+					Usage: "cf zdd-recover APP [--restore] [--client cli|api]",
+					Options: map[string]string{
+						"-restore": "Restore the venerable app even if the new version was pushed successfully",
+						"-client":  "cli (default) runs cf commands, api talks to the cloud controller's api directly",
+					},
+				},
+			},
+			{
+				Name:     "zdd-cleanup",
+				HelpText: "Delete the venerable app kept by push-zdd --bake-time once its bake period is over",
+				UsageDetails: plugin.Usage{
+					Usage: "cf zdd-cleanup APP [--force] [--client cli|api]",
+					Options: map[string]string{
+						"-force":  "Delete the venerable app even if it is still baking",
+						"-client": "cli (default) runs cf commands, api talks to the cloud controller's api directly",
+					},
+				},
+			},
+			{
+				Name:     "zdd-rollback",
+				HelpText: "Swap an app with its venerable app to go back to the previous version",
+				UsageDetails: plugin.Usage{
+					Usage: "cf zdd-rollback APP [--keep-failed] [--health-timeout 2m] [--health-window 10s] [--client cli|api]",
+					Options: map[string]string{
+						"-keep-failed":    "Stop the version being rolled back and keep it as APP-rollback instead of deleting it",
+						"-health-timeout": "How long to wait for the previous version to become healthy before undoing the rollback (default 2m)",
+						"-health-window":  "How long every instance of the previous version must have been running to be considered healthy (default 10s)",
+						"-client":         "cli (default) runs cf commands, api talks to the cloud controller's api directly",
+					},
+				},
+			},
+			{
+				Name:     "zdd-diff",
+				HelpText: "Show where a manifest and the running app differ, i.e. what push-zdd would change",
+				UsageDetails: plugin.Usage{
+					Usage: "cf zdd-diff APP [-f MANIFEST_PATH] [--vars-file VARS_FILE] [--var NAME=VALUE] [--output text|json] [--client cli|api]",
+					Options: map[string]string{
+						"f":          "Path to the manifest (default manifest.yml)",
+						"-vars-file": "File of values for the ((variables)) in the manifest, may be given more than once",
+						"-var":       "Value for a ((variable)) in the manifest given as name=value, may be given more than once",
+						"-output":    "text (default) or json, which writes the differences to stdout as json",
+						"-client":    "cli (default) runs cf commands, api talks to the cloud controller's api directly",
+					},
+				},
 			},
 		},
 	}
 }
 
+var exit = os.Exit
+
+//fatalIf - report err and exit, on stderr when stdout is being used for json
 func fatalIf(err error) {
 	if err != nil {
-		fmt.Fprintln(os.Stdout, "error:", err)
-		os.Exit(1)
+		var w io.Writer = os.Stdout
+		if _, ok := err.(summarisedError); ok {
+			w = os.Stderr
+		}
+		fmt.Fprintln(w, "error:", err)
+		exit(exitCode(err))
 	}
 }
 
-//appExists - check if appName is in output
-func appExists(output []string, appName string) bool {
-	for _, app := range output {
-		if strings.Contains(app, appName) {
-			return true
+//exit codes push-zdd finishes with, listed in the README
+const (
+	exitSucceeded      = 0
+	exitFailed         = 1
+	exitPreflight      = 2
+	exitRolledBack     = 3
+	exitRollbackFailed = 4
+	exitTimedOut       = 5
+	exitCancelled      = 6
+)
+
+//exitCode - the exit code for a run of push-zdd which returned err. With
+//several apps, a failed rollback outweighs everything else, otherwise the
+//first app to fail decides
+func exitCode(err error) int {
+	var (
+		rewindErr    *rewind.RewindError
+		preflightErr *preflightError
+		appsErr      *appsError
+	)
+
+	switch {
+	case err == nil:
+		return exitSucceeded
+	case errors.As(err, &preflightErr):
+		return exitPreflight
+	case errors.As(err, &rewindErr):
+		switch rewindErr.Kind() {
+		case rewind.RollbackFailed:
+			return exitRollbackFailed
+		case rewind.Cancelled:
+			return exitCancelled
+		case rewind.TimedOut:
+			return exitTimedOut
+		}
+		return exitRolledBack
+	case errors.As(err, &appsErr):
+		code := exitSucceeded
+		for _, outcome := range appsErr.outcomes {
+			appCode := exitCode(outcome.err)
+			if appCode == exitRollbackFailed || code == exitSucceeded {
+				code = appCode
+			}
 		}
+		if code == exitSucceeded {
+			return exitFailed
+		}
+		return code
 	}
-	return false
+	return exitFailed
 }
 
-//ParseArgs - parse given cli arguments
+//ParseArgs - parse given cli arguments, the app name is empty when the apps
+//to push should be read from the manifest
 func ParseArgs(args []string) (string, []string) {
 	args[0] = "push"
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		return "", args
+	}
 	appName := args[1]
 	return appName, args
 }
 
 //ErrNoManifest - error to return when there is no manifest if required
 var ErrNoManifest = errors.New("a manifest is required to push this application")
-
-func getAppList(appRepo *application_repo.ApplicationRepo) []string {
-	output, err := appRepo.ListApplicationsWithOutput()
-	fatalIf(err)
-	return output
-}
@@ -40,7 +40,7 @@ var _ = Describe("Command Syntax", func() {
 	)
 
 	BeforeEach(func() {
-		cliConn = &fakes.FakeCliConnection{}
+		cliConn = newCliConnection()
 		autopilotPlugin = &AutopilotPlugin{}
 	})
 
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xchapter7x/autopilot/journal"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//names of the steps which keep and later remove a baking venerable app
+const (
+	bakeStep    = "bake"
+	cleanupStep = "cleanup"
+)
+
+//bakeUntilEnv - the environment variable of a baking venerable app holding
+//when its bake period is over, so it is known wherever the plugin runs
+const bakeUntilEnv = "AUTOPILOT_BAKE_UNTIL"
+
+//getRetireAction - delete the venerable app, or stop it and keep it for the
+//bake period when one was given
+func (plugin AutopilotPlugin) getRetireAction() rewind.Action {
+	if plugin.options.bakeTime == 0 {
+		return plugin.journaled(deleteStep, plugin.getDeleteAction())
+	}
+
+	return plugin.journaled(bakeStep, rewind.Action{
+		Description: fmt.Sprintf("stop %s and keep it for %s", plugin.venerableAppName, plugin.options.bakeTime),
+		Forward: func() error {
+			if err := plugin.appRepo.StopApplication(plugin.venerableAppName); err != nil {
+				return err
+			}
+
+			expires := time.Now().Add(plugin.options.bakeTime).UTC()
+			if err := plugin.appRepo.SetEnv(plugin.venerableAppName, bakeUntilEnv, expires.Format(time.RFC3339)); err != nil {
+				return err
+			}
+
+			entry := plugin.entry(bakeStep, journal.Baking, nil)
+			entry.Expires = &expires
+			if err := plugin.journal.Record(entry); err != nil {
+				plugin.printf("warning: could not write to the deployment journal: %s\n", err)
+			}
+
+			plugin.printf("\n%s has been stopped and will be kept until %s, remove it with cf zdd-cleanup %s\n\n",
+				plugin.venerableAppName, expires.Local().Format(time.RFC1123), plugin.appName)
+			return nil
+		},
+		Reverse: func() error {
+			if err := plugin.appRepo.SetEnv(plugin.venerableAppName, bakeUntilEnv, ""); err != nil {
+				return err
+			}
+			return plugin.appRepo.StartApplication(plugin.venerableAppName)
+		},
+	})
+}
+
+//getBakedCleanupActions - delete a venerable app left baking by the previous
+//deployment, so this deployment can replace it
+func (plugin AutopilotPlugin) getBakedCleanupActions(apps []string) []rewind.Action {
+	if !contains(apps, plugin.venerableAppName) {
+		return nil
+	}
+
+	if _, baking := plugin.bakeDeadline(); !baking {
+		return nil
+	}
+
+	plugin.printf("\n%s was kept from the previous deployment, it will be deleted first\n\n", plugin.venerableAppName)
+	return []rewind.Action{
+		plugin.journaled(cleanupStep, plugin.getDeleteAction()),
+	}
+}
+
+//bakeDeadline - when the venerable app left baking by the last deployment
+//may be deleted, from the journal or, when the deployment was journaled
+//somewhere else, from the app itself
+func (plugin AutopilotPlugin) bakeDeadline() (time.Time, bool) {
+	deployment, err := plugin.journal.Latest()
+	if err == nil && deployment.Outcome(cleanupStep) == journal.Succeeded {
+		return time.Time{}, false
+	}
+	if entry, baking := deployment.Find(bakeStep, journal.Baking); baking && entry.Expires != nil {
+		return *entry.Expires, true
+	}
+
+	venerable, err := plugin.appRepo.GetApplication(plugin.venerableAppName)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return bakingUntil(venerable.EnvironmentVars)
+}
+
+//bakingUntil - the end of the bake period recorded in an app's environment
+func bakingUntil(env map[string]interface{}) (time.Time, bool) {
+	value, _ := env[bakeUntilEnv].(string)
+	expires, err := time.Parse(time.RFC3339, value)
+	return expires, err == nil
+}
+
+//cleanupDeployment - delete a venerable app once its bake period is over
+func (plugin AutopilotPlugin) cleanupDeployment(args []string) error {
+	if len(args) < 2 {
+		return ErrNoAppName
+	}
+	plugin.setAppName(args[1])
+	force := hasFlag(args[2:], "--force")
+
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil {
+		return err
+	}
+
+	if !contains(apps, plugin.venerableAppName) {
+		fmt.Printf("\nthere is nothing to clean up for %s\n\n", plugin.appName)
+		return nil
+	}
+
+	expires, baking := plugin.bakeDeadline()
+	switch {
+	case force:
+	case !baking:
+		return fmt.Errorf("neither the journal nor %s show it being kept to bake, use --force to delete it anyway", plugin.venerableAppName)
+	case time.Now().Before(expires):
+		fmt.Printf("\n%s is baking until %s, use --force to delete it now\n\n",
+			plugin.venerableAppName, expires.Local().Format(time.RFC1123))
+		return nil
+	}
+
+	plugin.record(cleanupStep, journal.Started, journal.Started, nil)
+	err = plugin.appRepo.DeleteApplication(plugin.venerableAppName)
+	plugin.record(cleanupStep, journal.Succeeded, journal.Failed, err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s has been deleted\n\n", plugin.venerableAppName)
+	return nil
+}
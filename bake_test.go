@@ -0,0 +1,176 @@
+package main_test
+
+import (
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+	"github.com/xchapter7x/autopilot/journal"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Bake period", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		appJournal      *journal.Journal
+		exitCode        int
+		restoreExit     func()
+	)
+
+	bakeUntil := func(expires time.Time) {
+		Ω(appJournal.Record(journal.Entry{App: "myapp", Step: journal.DeploymentStep, Outcome: journal.Started})).Should(Succeed())
+		Ω(appJournal.Record(journal.Entry{App: "myapp", Step: "bake", Outcome: journal.Baking, Expires: &expires})).Should(Succeed())
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+			plugin_models.GetAppsModel{Name: "myapp-venerable"},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+		appJournal = journal.New(journal.DefaultDir(), "myapp")
+	})
+
+	AfterEach(func() {
+		os.Remove(appJournal.Path())
+		restoreExit()
+	})
+
+	Context("when push-zdd is given a bake time", func() {
+		BeforeEach(func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "myapp"},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--bake-time", "10m"})
+		})
+
+		It("then it should stop the venerable app instead of deleting it", func() {
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)[:3]).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"stop", "myapp-venerable"},
+			}))
+		})
+
+		It("then it should record when the bake period ends on the venerable app", func() {
+			Ω(cfCommands(cliConn)).Should(HaveLen(4))
+			setEnv := cfCommands(cliConn)[3]
+			Ω(setEnv[:3]).Should(Equal([]string{"set-env", "myapp-venerable", "AUTOPILOT_BAKE_UNTIL"}))
+			expires, err := time.Parse(time.RFC3339, setEnv[3])
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(expires).Should(BeTemporally("~", time.Now().Add(10*time.Minute), time.Minute))
+		})
+
+		It("then it should record when the bake period ends", func() {
+			deployment, err := appJournal.Latest()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			entry, ok := deployment.Find("bake", journal.Baking)
+			Ω(ok).Should(BeTrue())
+			Ω(*entry.Expires).Should(BeTemporally("~", time.Now().Add(10*time.Minute), time.Minute))
+		})
+	})
+
+	Context("when the previous deployment left the venerable app baking", func() {
+		It("then the next deployment should delete it first", func() {
+			bakeUntil(time.Now().Add(time.Hour))
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"delete", "myapp-venerable", "-f"},
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"delete", "myapp-venerable", "-f"},
+			}))
+		})
+	})
+
+	Describe("zdd-cleanup", func() {
+		Context("when the bake period is over", func() {
+			It("then it should delete the venerable app", func() {
+				bakeUntil(time.Now().Add(-time.Minute))
+				autopilotPlugin.Run(cliConn, []string{"zdd-cleanup", "myapp"})
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(cfCommands(cliConn)).Should(Equal([][]string{{"delete", "myapp-venerable", "-f"}}))
+			})
+		})
+
+		Context("when the venerable app is still baking", func() {
+			BeforeEach(func() {
+				bakeUntil(time.Now().Add(time.Hour))
+			})
+
+			It("then it should leave it alone", func() {
+				autopilotPlugin.Run(cliConn, []string{"zdd-cleanup", "myapp"})
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+			})
+
+			It("then it should delete it when forced to", func() {
+				autopilotPlugin.Run(cliConn, []string{"zdd-cleanup", "myapp", "--force"})
+
+				Ω(cfCommands(cliConn)).Should(Equal([][]string{{"delete", "myapp-venerable", "-f"}}))
+			})
+		})
+
+		Context("when the bake period was recorded by a deployment on another machine", func() {
+			var (
+				foundation  *repofakes.FakeFoundation
+				restoreRepo func()
+			)
+
+			BeforeEach(func() {
+				foundation = repofakes.NewFakeFoundation()
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp"})
+				restoreRepo = SetApplicationRepo(foundation)
+			})
+
+			AfterEach(func() {
+				restoreRepo()
+			})
+
+			It("then it should leave the venerable app alone while it is baking", func() {
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable", Env: map[string]string{
+					"AUTOPILOT_BAKE_UNTIL": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+				}})
+				autopilotPlugin.Run(cliConn, []string{"zdd-cleanup", "myapp"})
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp", "myapp-venerable"}))
+			})
+
+			It("then it should delete the venerable app once the bake period is over", func() {
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable", Env: map[string]string{
+					"AUTOPILOT_BAKE_UNTIL": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+				}})
+				autopilotPlugin.Run(cliConn, []string{"zdd-cleanup", "myapp"})
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			})
+		})
+
+		Context("when the journal does not show the venerable app baking", func() {
+			It("then it should refuse to delete it", func() {
+				autopilotPlugin.Run(cliConn, []string{"zdd-cleanup", "myapp"})
+
+				Ω(exitCode).Should(Equal(1))
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//names of the steps taken by the blue-green strategy
+const (
+	pushGreenStep   = "push-green"
+	tempRouteStep   = "map-temporary-route"
+	mapRoutesStep   = "map-routes"
+	unmapRoutesStep = "unmap-routes"
+	dropRouteStep   = "delete-temporary-route"
+	retireStep      = "retire"
+	promoteStep     = "promote"
+
+	//restoreRoutesStep - zdd-recover giving the old app back its routes
+	restoreRoutesStep = "restore-routes"
+)
+
+//getBlueGreenActions - push the new version alongside the old one under a
+//temporary name and route, then move the old app's routes over to it
+func (plugin AutopilotPlugin) getBlueGreenActions(argList []string) ([]rewind.Action, error) {
+	app, err := plugin.appRepo.GetApplication(plugin.appName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(app.Routes) == 0 {
+		return nil, fmt.Errorf("%s has no routes to remap, use the %s strategy instead", plugin.appName, manifestStrategy)
+	}
+
+	greenAppName := plugin.appName + "-green"
+	tempRoute := plugin_models.GetApp_RouteSummary{
+		Host:   greenAppName,
+		Domain: app.Routes[0].Domain,
+	}
+
+	greenArgs := append([]string{}, argList...)
+	greenArgs[1] = greenAppName
+	greenArgs = append(greenArgs, "--no-route")
+
+	deleteGreen := func() error {
+		return plugin.appRepo.DeleteApplication(greenAppName)
+	}
+
+	plugin.printf("\n%s was found, using blue-green deployment\n\n", plugin.appName)
+	actionList := []rewind.Action{
+		plugin.journaled(pushGreenStep, rewind.Action{
+			Description: describePush(greenArgs),
+			Forward: func() error {
+				return plugin.pushGreen(greenAppName, greenArgs)
+			},
+			Reverse:         deleteGreen,
+			ReversePrevious: deleteGreen,
+		}),
+		plugin.journaled(tempRouteStep, rewind.Action{
+			Description: fmt.Sprintf("map the temporary route %s to %s", describeRoutes(tempRoute), greenAppName),
+			Forward: func() error {
+				return plugin.mapRoutes(greenAppName, tempRoute)
+			},
+			Reverse: func() error {
+				return plugin.appRepo.DeleteRoute(tempRoute.Domain.Name, tempRoute.Host)
+			},
+		}),
+	}
+	actionList = append(actionList, plugin.getVerificationActions(greenAppName, plugin.appName, greenArgs)...)
+
+	return append(actionList,
+		plugin.journaled(mapRoutesStep, rewind.Action{
+			Description: fmt.Sprintf("map %s to %s", describeRoutes(app.Routes...), greenAppName),
+			Forward: func() error {
+				return plugin.mapRoutes(greenAppName, app.Routes...)
+			},
+			Reverse: func() error {
+				return plugin.unmapRoutes(greenAppName, app.Routes...)
+			},
+			ReversePrevious: func() error {
+				return plugin.unmapRoutes(greenAppName, app.Routes...)
+			},
+		}),
+		plugin.journaled(unmapRoutesStep, rewind.Action{
+			Description: fmt.Sprintf("unmap %s from %s", describeRoutes(app.Routes...), plugin.appName),
+			Forward: func() error {
+				return plugin.unmapRoutes(plugin.appName, app.Routes...)
+			},
+			Reverse: func() error {
+				return plugin.mapRoutes(plugin.appName, app.Routes...)
+			},
+			ReversePrevious: func() error {
+				return plugin.mapRoutes(plugin.appName, app.Routes...)
+			},
+		}),
+		plugin.journaled(dropRouteStep, rewind.Action{
+			Description: fmt.Sprintf("delete the temporary route %s", describeRoutes(tempRoute)),
+			Forward: func() error {
+				return plugin.appRepo.DeleteRoute(tempRoute.Domain.Name, tempRoute.Host)
+			},
+			Reverse: func() error {
+				return plugin.mapRoutes(greenAppName, tempRoute)
+			},
+		}),
+		plugin.journaled(retireStep, rewind.Action{
+			Description: fmt.Sprintf("rename %s to %s", plugin.appName, plugin.venerableAppName),
+			Retry:       noRetry,
+			Forward: func() error {
+				return plugin.appRepo.RenameApplication(plugin.appName, plugin.venerableAppName)
+			},
+			Reverse: func() error {
+				return plugin.appRepo.RenameApplication(plugin.venerableAppName, plugin.appName)
+			},
+			ReversePrevious: func() error {
+				return plugin.undoRename(plugin.appName, plugin.venerableAppName)
+			},
+		}),
+		plugin.journaled(promoteStep, rewind.Action{
+			Description: fmt.Sprintf("rename %s to %s", greenAppName, plugin.appName),
+			Retry:       noRetry,
+			Forward: func() error {
+				return plugin.appRepo.RenameApplication(greenAppName, plugin.appName)
+			},
+			Reverse: func() error {
+				return plugin.appRepo.RenameApplication(plugin.appName, greenAppName)
+			},
+			ReversePrevious: func() error {
+				return plugin.undoRename(greenAppName, plugin.appName)
+			},
+		}),
+		plugin.getRetireAction(),
+	), nil
+}
+
+//pushGreen - push the new version as greenAppName, from a manifest describing
+//only it, as cf push cannot rename one app of a manifest describing several
+func (plugin AutopilotPlugin) pushGreen(greenAppName string, greenArgs []string) error {
+	if plugin.manifest == nil {
+		return plugin.appRepo.PushApplication(greenArgs)
+	}
+
+	path, err := writeExpandedManifest(plugin.manifest.Only(plugin.appName, greenAppName))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	return plugin.appRepo.PushApplication(withFlag(greenArgs, "-f", path))
+}
+
+func (plugin AutopilotPlugin) mapRoutes(appName string, routes ...plugin_models.GetApp_RouteSummary) error {
+	for _, route := range routes {
+		if err := plugin.appRepo.MapRoute(appName, route.Domain.Name, route.Host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (plugin AutopilotPlugin) unmapRoutes(appName string, routes ...plugin_models.GetApp_RouteSummary) error {
+	for _, route := range routes {
+		if err := plugin.appRepo.UnmapRoute(appName, route.Domain.Name, route.Host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//describeRoutes - the routes as a comma separated list of urls
+func describeRoutes(routes ...plugin_models.GetApp_RouteSummary) string {
+	urls := []string{}
+	for _, route := range routes {
+		if route.Host == "" {
+			urls = append(urls, route.Domain.Name)
+		} else {
+			urls = append(urls, route.Host+"."+route.Domain.Name)
+		}
+	}
+	return strings.Join(urls, ", ")
+}
@@ -0,0 +1,165 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	"github.com/xchapter7x/autopilot/manifest"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Blue-green strategy", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		greenApp        plugin_models.GetAppModel
+		exitCode        int
+		restoreExit     func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		greenApp = plugin_models.GetAppModel{
+			Name:             "myapp-green",
+			State:            "started",
+			InstanceCount:    1,
+			RunningInstances: 1,
+		}
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.GetAppStub = func(name string) (plugin_models.GetAppModel, error) {
+			if name == "myapp-green" {
+				return greenApp, nil
+			}
+			return plugin_models.GetAppModel{
+				Name: "myapp",
+				Routes: []plugin_models.GetApp_RouteSummary{
+					{Host: "myapp", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+					{Host: "", Domain: plugin_models.GetApp_DomainFields{Name: "apps.example.com"}},
+				},
+			}, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+	})
+
+	Context("when the new version starts", func() {
+		var (
+			manifestPath   string
+			pushedPath     string
+			pushedManifest string
+		)
+
+		BeforeEach(func() {
+			manifestFile, err := ioutil.TempFile("", "manifest")
+			Ω(err).ShouldNot(HaveOccurred())
+			manifestFile.WriteString("applications:\n- name: myapp\n  memory: 1G\n- name: worker\n")
+			manifestFile.Close()
+			manifestPath = manifestFile.Name()
+
+			pushedPath, pushedManifest = "", ""
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				if args[0] == "push" {
+					pushedPath = args[len(args)-1]
+					m, err := manifest.Load(pushedPath, nil)
+					Ω(err).ShouldNot(HaveOccurred())
+					data, _ := m.Marshal()
+					pushedManifest = string(data)
+				}
+				return nil, nil
+			}
+
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "blue-green", "-f", manifestPath})
+		})
+
+		AfterEach(func() {
+			os.Remove(manifestPath)
+		})
+
+		It("then it should push the new version from a manifest describing only it", func() {
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)[0]).Should(Equal([]string{"push", "myapp-green", "--no-route", "-f", pushedPath}))
+			Ω(pushedPath).ShouldNot(Equal(manifestPath))
+			Ω(pushedManifest).Should(Equal("applications:\n- memory: 1G\n  name: myapp-green\n"))
+			_, err := os.Stat(pushedPath)
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		It("then it should move the old app's routes to the new version before retiring the old app", func() {
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)[1:]).Should(Equal([][]string{
+				{"map-route", "myapp-green", "example.com", "-n", "myapp-green"},
+				{"map-route", "myapp-green", "example.com", "-n", "myapp"},
+				{"map-route", "myapp-green", "apps.example.com"},
+				{"unmap-route", "myapp", "example.com", "-n", "myapp"},
+				{"unmap-route", "myapp", "apps.example.com"},
+				{"delete-route", "example.com", "-n", "myapp-green", "-f"},
+				{"rename", "myapp", "myapp-venerable"},
+				{"rename", "myapp-green", "myapp"},
+				{"delete", "myapp-venerable", "-f"},
+			}))
+		})
+	})
+
+	Context("when the new version does not start", func() {
+		BeforeEach(func() {
+			greenApp.State = "stopped"
+			greenApp.RunningInstances = 0
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "blue-green", "--health-timeout", "0s"})
+		})
+
+		It("then it should remove the temporary route and the new version without touching the old app", func() {
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"push", "myapp-green", "--no-route"},
+				{"map-route", "myapp-green", "example.com", "-n", "myapp-green"},
+				{"delete-route", "example.com", "-n", "myapp-green", "-f"},
+				{"delete", "myapp-green", "-f"},
+			}))
+		})
+	})
+
+	Context("when a green app is left over from an earlier deployment", func() {
+		It("then it should fail the pre-flight checks without touching any apps", func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "myapp"},
+				plugin_models.GetAppsModel{Name: "myapp-green"},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "blue-green"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the new version would not be started", func() {
+		It("then it should fail the pre-flight checks without touching any apps", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "blue-green", "--no-start"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when an unknown strategy is given", func() {
+		It("then it should fail without touching any apps", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "purple"})
+
+			Ω(exitCode).Should(Equal(1))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xchapter7x/autopilot/journal"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//names of the steps taken by the canary strategy
+const (
+	pushCanaryStep = "push-canary"
+	scaleStep      = "scale"
+)
+
+//venerableInstancesKey - the journal metadata holding how many instances the
+//venerable app had before it was scaled down
+const venerableInstancesKey = "venerable-instances"
+
+//getCanaryActions - push the new version with a few instances alongside the
+//venerable app, then shift instances from the venerable app to the new one
+func (plugin AutopilotPlugin) getCanaryActions(argList []string) ([]rewind.Action, error) {
+	app, err := plugin.appRepo.GetApplication(plugin.appName)
+	if err != nil {
+		return nil, err
+	}
+
+	originalInstances := app.InstanceCount
+	if originalInstances < 1 {
+		originalInstances = 1
+	}
+
+	canaryArgs := withFlag(argList, "-i", strconv.Itoa(plugin.options.canaryInstances))
+	deleteCanary := func() error {
+		return plugin.appRepo.DeleteApplication(plugin.appName)
+	}
+
+	//the venerable app's instances are journaled so a rollback, or zdd-recover
+	//after a crash, can give it back its capacity
+	renameAction := plugin.getRenameAction()
+	renameAction.Metadata = map[string]string{venerableInstancesKey: strconv.Itoa(originalInstances)}
+
+	plugin.printf("\n%s was found, using canary deployment\n\n", plugin.appName)
+	actionList := []rewind.Action{
+		plugin.journaled(renameStep, renameAction),
+		plugin.journaled(pushCanaryStep, rewind.Action{
+			Description: describePush(canaryArgs),
+			Forward: func() error {
+				return plugin.appRepo.PushApplication(canaryArgs)
+			},
+			Reverse:         deleteCanary,
+			ReversePrevious: deleteCanary,
+		}),
+	}
+	actionList = append(actionList, plugin.getVerificationActions(plugin.appName, plugin.venerableAppName, canaryArgs)...)
+
+	restoreVenerable := func() error {
+		return plugin.restoreVenerable(originalInstances)
+	}
+
+	for i, percent := range plugin.options.canarySteps {
+		scaleAction := plugin.getScaleAction(percent, originalInstances)
+		//a step which fails part way restores the venerable app itself, and
+		//restoring it once undoes every completed scaling step
+		scaleAction.ReversePrevious = restoreVenerable
+		if i == 0 {
+			scaleAction.Reverse = restoreVenerable
+		}
+		actionList = append(actionList, plugin.journaled(fmt.Sprintf("%s-%d", scaleStep, percent), scaleAction))
+	}
+
+	return append(actionList, plugin.getRetireAction()), nil
+}
+
+//getScaleAction - move percent of the original instances over to the new
+//version, wait, then check it is still healthy
+func (plugin AutopilotPlugin) getScaleAction(percent, originalInstances int) rewind.Action {
+	newInstances := (originalInstances*percent + 99) / 100
+	if newInstances < plugin.options.canaryInstances {
+		newInstances = plugin.options.canaryInstances
+	}
+	venerableInstances := originalInstances - newInstances
+	if venerableInstances < 0 {
+		venerableInstances = 0
+	}
+
+	return rewind.Action{
+		Description: fmt.Sprintf("scale %s to %d and %s to %d instances (%d%%), wait %s and check %s is healthy",
+			plugin.appName, newInstances, plugin.venerableAppName, venerableInstances, percent, plugin.options.canaryPause, plugin.appName),
+		Forward: func() error {
+			plugin.printf("\nscaling %s to %d and %s to %d instances (%d%%)\n\n",
+				plugin.appName, newInstances, plugin.venerableAppName, venerableInstances, percent)
+
+			if err := plugin.appRepo.ScaleApplication(plugin.appName, newInstances); err != nil {
+				return err
+			}
+
+			var err error
+			if venerableInstances > 0 {
+				err = plugin.appRepo.ScaleApplication(plugin.venerableAppName, venerableInstances)
+			} else {
+				err = plugin.appRepo.StopApplication(plugin.venerableAppName)
+			}
+			if err != nil {
+				return err
+			}
+
+			time.Sleep(plugin.options.canaryPause)
+			return plugin.getVerifyAction(plugin.appName).Forward()
+		},
+	}
+}
+
+//withFlag - set flag to value in args, replacing any value already given
+func withFlag(args []string, flag, value string) []string {
+	result := []string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == flag {
+			i++
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return append(result, flag, value)
+}
+
+//restoreVenerable - start the venerable app with the instances it had before
+//the deployment, as journaled by the rename step, or fallback when the journal
+//does not say
+func (plugin AutopilotPlugin) restoreVenerable(fallback int) error {
+	instances := fallback
+	if deployment, err := plugin.journal.Latest(); err == nil {
+		if n, ok := journaledInstances(deployment); ok {
+			instances = n
+		}
+	}
+
+	if err := plugin.appRepo.ScaleApplication(plugin.venerableAppName, instances); err != nil {
+		return err
+	}
+	return plugin.appRepo.StartApplication(plugin.venerableAppName)
+}
+
+//journaledInstances - how many instances the venerable app had before the
+//deployment, if the journal recorded it
+func journaledInstances(deployment journal.Deployment) (int, bool) {
+	value, ok := deployment.Metadata(renameStep, venerableInstancesKey)
+	if !ok {
+		return 0, false
+	}
+	instances, err := strconv.Atoi(value)
+	return instances, err == nil && instances > 0
+}
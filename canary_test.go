@@ -0,0 +1,147 @@
+package main_test
+
+import (
+	"errors"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Canary strategy", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		unhealthyAt     int
+		canaryInstances int
+		exitCode        int
+		restoreExit     func()
+	)
+
+	BeforeEach(func() {
+		unhealthyAt = -1
+		canaryInstances = 0
+		restoreExit = recordExit(&exitCode)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "scale" && args[1] == "myapp" {
+				canaryInstances, _ = strconv.Atoi(args[3])
+			}
+			return nil, nil
+		}
+		cliConn.GetAppStub = func(name string) (plugin_models.GetAppModel, error) {
+			app := plugin_models.GetAppModel{
+				Name:             name,
+				State:            "started",
+				InstanceCount:    4,
+				RunningInstances: 4,
+			}
+			if unhealthyAt >= 0 && name == "myapp" && canaryInstances >= unhealthyAt {
+				app.State = "crashed"
+				app.RunningInstances = 0
+			}
+			return app, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+	})
+
+	run := func() {
+		autopilotPlugin.Run(cliConn, []string{
+			"push-zdd", "myapp",
+			"--strategy", "canary",
+			"--canary-instances", "1",
+			"--steps", "50,100",
+			"--canary-pause", "0s",
+			"--health-timeout", "0s",
+			"-i", "4",
+		})
+	}
+
+	Context("when the new version stays healthy", func() {
+		BeforeEach(run)
+
+		It("then it should shift the instances over in steps and delete the venerable app", func() {
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp", "-i", "1"},
+				{"scale", "myapp", "-i", "2"},
+				{"scale", "myapp-venerable", "-i", "2"},
+				{"scale", "myapp", "-i", "4"},
+				{"stop", "myapp-venerable"},
+				{"delete", "myapp-venerable", "-f"},
+			}))
+		})
+	})
+
+	Context("when the new version becomes unhealthy part way through", func() {
+		BeforeEach(func() {
+			unhealthyAt = 2
+			run()
+		})
+
+		It("then it should give the venerable app back its original instances and remove the new version", func() {
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp", "-i", "1"},
+				{"scale", "myapp", "-i", "2"},
+				{"scale", "myapp-venerable", "-i", "2"},
+				{"scale", "myapp-venerable", "-i", "4"},
+				{"start", "myapp-venerable"},
+				{"delete", "myapp", "-f"},
+				{"rename", "myapp-venerable", "myapp"},
+			}))
+		})
+	})
+
+	Context("when a later step fails part way through", func() {
+		It("then it should give the venerable app back all of its instances", func() {
+			foundation := repofakes.NewFakeFoundation()
+			original := foundation.AddApp(repofakes.FakeApp{Name: "myapp", Instances: 4}).Guid
+			foundation.FailOn("StopApplication", "myapp-venerable", errors.New("stop failed"), 0)
+			restoreRepo := SetApplicationRepo(foundation)
+			defer restoreRepo()
+
+			run()
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			app := foundation.App("myapp")
+			Ω(app.Guid).Should(Equal(original))
+			Ω(app.Instances).Should(Equal(4))
+			Ω(app.Started).Should(BeTrue())
+		})
+	})
+
+	Context("when the steps do not finish at 100", func() {
+		It("then it should fail without touching any apps", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "canary", "--steps", "25,50"})
+
+			Ω(exitCode).Should(Equal(1))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+	Context("when the new version would not be started", func() {
+		It("then it should fail the pre-flight checks without touching any apps", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "canary", "--no-start"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,57 @@
+package main_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	"github.com/xchapter7x/autopilot/application_repo"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+)
+
+var _ = Describe("--client", func() {
+	var (
+		cliConn     *fakes.FakeCliConnection
+		created     func() []application_repo.Repo
+		restoreRepo func()
+		restoreExit func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = SetExit(func(int) {})
+		created, restoreRepo = WatchApplicationRepo()
+		cliConn = newCliConnection()
+	})
+
+	AfterEach(func() {
+		restoreRepo()
+		restoreExit()
+	})
+
+	for _, command := range []string{"push-zdd", "zdd-recover", "zdd-cleanup", "zdd-rollback", "zdd-diff"} {
+		command := command
+
+		Context("when "+command+" is given --client api", func() {
+			It("then it should talk to the cloud controller's api", func() {
+				(&AutopilotPlugin{}).Run(cliConn, []string{command, "myapp", "--client", "api"})
+
+				Ω(created()).ShouldNot(BeEmpty())
+				for _, repo := range created() {
+					Ω(repo).Should(BeAssignableToTypeOf(&application_repo.CloudControllerRepo{}))
+				}
+			})
+		})
+
+		Context("when "+command+" is not given --client", func() {
+			It("then it should run cf commands", func() {
+				(&AutopilotPlugin{}).Run(cliConn, []string{command, "myapp"})
+
+				Ω(created()).ShouldNot(BeEmpty())
+				for _, repo := range created() {
+					Ω(repo).Should(BeAssignableToTypeOf(&application_repo.ApplicationRepo{}))
+				}
+			})
+		})
+	}
+})
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/manifest"
+)
+
+//diffEvent - the type of the json object written by zdd-diff --output json
+const diffEvent = "diff"
+
+//drift - how the manifest and the running app differ for zdd-diff
+type drift struct {
+	Type        string       `json:"type"`
+	App         string       `json:"app"`
+	Manifest    string       `json:"manifest"`
+	Differences []difference `json:"differences"`
+}
+
+//difference - a property the manifest and the running app disagree on, an
+//empty value is one which is not set
+type difference struct {
+	Property string `json:"property"`
+	Manifest string `json:"manifest,omitempty"`
+	Running  string `json:"running,omitempty"`
+}
+
+//diffDeployment - compare the manifest with the running app, showing what a
+//deployment would change
+func (plugin AutopilotPlugin) diffDeployment(args []string) error {
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		return ErrNoAppName
+	}
+	appName := args[1]
+
+	m, _, cleanup, err := loadManifest(args, plugin.options)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if m == nil {
+		return ErrNoManifest
+	}
+
+	declared, ok := m.Application(appName)
+	if !ok {
+		return fmt.Errorf("%s is not one of the applications in manifest %s", appName, manifestPath(args))
+	}
+
+	running, err := plugin.appRepo.GetApplication(appName)
+	if err != nil {
+		return err
+	}
+
+	d := drift{
+		Type:        diffEvent,
+		App:         appName,
+		Manifest:    manifestPath(args),
+		Differences: differences(declared, running),
+	}
+	if plugin.options.output == outputJSON {
+		return json.NewEncoder(stdout).Encode(d)
+	}
+	d.print()
+	return nil
+}
+
+//print - write the differences as a table
+func (d drift) print() {
+	if len(d.Differences) == 0 {
+		fmt.Fprintf(stdout, "\n%s matches manifest %s\n\n", d.App, d.Manifest)
+		return
+	}
+
+	fmt.Fprintf(stdout, "\n%s differs from manifest %s:\n\n", d.App, d.Manifest)
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  PROPERTY\tMANIFEST\tRUNNING")
+	for _, diff := range d.Differences {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", diff.Property, orUnset(diff.Manifest), orUnset(diff.Running))
+	}
+	w.Flush()
+	fmt.Fprintf(stdout, "\n%d differences, a deployment would replace the running values with the manifest's\n\n", len(d.Differences))
+}
+
+//differences - where declared and running disagree. Memory, disk quota,
+//buildpack and stack left out of the manifest are not compared as cf decides
+//them, but instances, routes, services and environment variables are, as the
+//new version is pushed without them
+func differences(declared manifest.Application, running plugin_models.GetAppModel) (diffs []difference) {
+	add := func(property, declared, running string) {
+		if declared != running {
+			diffs = append(diffs, difference{Property: property, Manifest: declared, Running: running})
+		}
+	}
+
+	instances := declared.Instances
+	if instances == 0 {
+		instances = 1
+	}
+	add("instances", strconv.Itoa(instances), strconv.Itoa(running.InstanceCount))
+
+	if megabytes, err := manifest.ParseMegabytes(declared.Memory); declared.Memory != "" && err == nil && int64(megabytes) != running.Memory {
+		add("memory", declared.Memory, fmt.Sprintf("%dM", running.Memory))
+	}
+	if megabytes, err := manifest.ParseMegabytes(declared.DiskQuota); declared.DiskQuota != "" && err == nil && int64(megabytes) != running.DiskQuota {
+		add("disk_quota", declared.DiskQuota, fmt.Sprintf("%dM", running.DiskQuota))
+	}
+	if declared.Buildpack != "" {
+		add("buildpack", declared.Buildpack, running.BuildpackUrl)
+	}
+	if declared.Stack != "" && running.Stack != nil {
+		add("stack", declared.Stack, running.Stack.Name)
+	}
+
+	diffs = append(diffs, routeDifferences(declared, running)...)
+
+	services := []string{}
+	for _, service := range running.Services {
+		services = append(services, service.Name)
+	}
+	diffs = append(diffs, setDifferences("service", declared.Services, services)...)
+
+	diffs = append(diffs, envDifferences(declared.Env, running.EnvironmentVars)...)
+	return
+}
+
+//routeDifferences - routes only the manifest or only the running app has.
+//When the manifest has no routes the running app's route named after it is
+//taken to be the default route cf push will give the new version
+func routeDifferences(declared manifest.Application, running plugin_models.GetAppModel) []difference {
+	routes := []string{}
+	for _, route := range running.Routes {
+		if len(declared.Routes) == 0 && !declared.NoRoute && route.Host == declared.Name {
+			continue
+		}
+		routes = append(routes, describeRoutes(route))
+	}
+
+	declaredRoutes := []string{}
+	for _, route := range declared.Routes {
+		declaredRoutes = append(declaredRoutes, route.Route)
+	}
+	return setDifferences("route", declaredRoutes, routes)
+}
+
+//setDifferences - the members of only one of declared and running, each named
+//property
+func setDifferences(property string, declared, running []string) (diffs []difference) {
+	inDeclared, inRunning := map[string]bool{}, map[string]bool{}
+	for _, member := range declared {
+		inDeclared[member] = true
+	}
+	for _, member := range running {
+		inRunning[member] = true
+	}
+
+	for _, member := range sortedKeys(inDeclared) {
+		if !inRunning[member] {
+			diffs = append(diffs, difference{Property: property, Manifest: member})
+		}
+	}
+	for _, member := range sortedKeys(inRunning) {
+		if !inDeclared[member] {
+			diffs = append(diffs, difference{Property: property, Running: member})
+		}
+	}
+	return
+}
+
+//envDifferences - the environment variables whose values differ, or which
+//only one of declared and running sets
+func envDifferences(declared map[string]string, running map[string]interface{}) (diffs []difference) {
+	names := map[string]bool{}
+	for name := range declared {
+		names[name] = true
+	}
+	for name := range running {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		runningValue := ""
+		if value, ok := running[name]; ok {
+			runningValue = envValue(value)
+		}
+		if declared[name] != runningValue {
+			diffs = append(diffs, difference{Property: "env " + name, Manifest: declared[name], Running: runningValue})
+		}
+	}
+	return
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func orUnset(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
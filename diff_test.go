@@ -0,0 +1,181 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("zdd-diff", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		manifestDir     string
+		output          *bytes.Buffer
+		exitCode        int
+		restores        []func()
+	)
+
+	write := func(content string) string {
+		path := filepath.Join(manifestDir, "manifest.yml")
+		Ω(ioutil.WriteFile(path, []byte(content), 0600)).Should(Succeed())
+		return path
+	}
+
+	route := func(host string) plugin_models.GetApp_RouteSummary {
+		return plugin_models.GetApp_RouteSummary{Host: host, Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}}
+	}
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		restores = []func(){
+			recordExit(&exitCode),
+			SetStdout(output),
+		}
+
+		var err error
+		manifestDir, err = ioutil.TempDir("", "autopilot-diff")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		cliConn = &fakes.FakeCliConnection{}
+		cliConn.GetAppReturns(plugin_models.GetAppModel{
+			Name:            "myapp",
+			InstanceCount:   6,
+			Memory:          1024,
+			DiskQuota:       1024,
+			BuildpackUrl:    "go_buildpack",
+			Stack:           &plugin_models.GetApp_Stack{Name: "cflinuxfs3"},
+			EnvironmentVars: map[string]interface{}{"REGION": "eu", "DEBUG": "true"},
+			Services:        []plugin_models.GetApp_ServiceSummary{{Name: "db"}, {Name: "cache"}},
+			Routes:          []plugin_models.GetApp_RouteSummary{route("myapp"), route("www")},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		for _, restore := range restores {
+			restore()
+		}
+		os.RemoveAll(manifestDir)
+	})
+
+	Context("when the running app has drifted from the manifest", func() {
+		var path string
+
+		BeforeEach(func() {
+			path = write(`---
+applications:
+- name: myapp
+  instances: 2
+  memory: 1G
+  disk_quota: 2G
+  buildpack: go_buildpack
+  stack: cflinuxfs4
+  routes:
+  - route: myapp.example.com
+  - route: api.example.com
+  services:
+  - db
+  env:
+    REGION: us
+`)
+		})
+
+		It("then it should list every difference as json", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff", "myapp", "-f", path, "--output", "json"})
+
+			Ω(exitCode).Should(Equal(0))
+			var drift struct {
+				Type        string
+				App         string
+				Differences []map[string]string
+			}
+			Ω(json.Unmarshal(output.Bytes(), &drift)).Should(Succeed())
+			Ω(drift.Type).Should(Equal("diff"))
+			Ω(drift.App).Should(Equal("myapp"))
+			Ω(drift.Differences).Should(Equal([]map[string]string{
+				{"property": "instances", "manifest": "2", "running": "6"},
+				{"property": "disk_quota", "manifest": "2G", "running": "1024M"},
+				{"property": "stack", "manifest": "cflinuxfs4", "running": "cflinuxfs3"},
+				{"property": "route", "manifest": "api.example.com"},
+				{"property": "route", "running": "www.example.com"},
+				{"property": "service", "running": "cache"},
+				{"property": "env DEBUG", "running": "true"},
+				{"property": "env REGION", "manifest": "us", "running": "eu"},
+			}))
+		})
+
+		It("then it should print them as a table", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(output.String()).Should(ContainSubstring("myapp differs from manifest " + path))
+			Ω(output.String()).Should(MatchRegexp(`instances\s+2\s+6\n`))
+			Ω(output.String()).Should(MatchRegexp(`route\s+-\s+www.example.com\n`))
+			Ω(output.String()).Should(ContainSubstring("8 differences"))
+		})
+
+		It("then it should not change anything", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff", "myapp", "-f", path})
+
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the manifest matches the running app", func() {
+		It("then it should say so", func() {
+			path := write(`---
+applications:
+- name: myapp
+  instances: 6
+  memory: 1024M
+  routes:
+  - route: myapp.example.com
+  - route: www.example.com
+  services: [db, cache]
+  env:
+    REGION: eu
+    DEBUG: true
+`)
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(output.String()).Should(ContainSubstring("myapp matches manifest " + path))
+		})
+	})
+
+	Context("when the manifest leaves out the app's routes", func() {
+		It("then it should not count the default route as drift", func() {
+			path := write("applications:\n- name: myapp\n  instances: 6\n  services: [db, cache]\n  env:\n    REGION: eu\n    DEBUG: true\n")
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff", "myapp", "-f", path, "--output", "json"})
+
+			Ω(output.String()).Should(ContainSubstring(`"differences":[{"property":"route","running":"www.example.com"}]`))
+		})
+	})
+
+	Context("when there is no manifest", func() {
+		It("then it should fail", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff", "myapp", "-f", filepath.Join(manifestDir, "missing.yml")})
+
+			Ω(exitCode).Should(Equal(1))
+		})
+	})
+
+	Context("when no app is given", func() {
+		It("then it should fail", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-diff"})
+
+			Ω(exitCode).Should(Equal(1))
+		})
+	})
+})
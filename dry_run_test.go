@@ -0,0 +1,104 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Dry run", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		output          *bytes.Buffer
+		exitCode        int
+		restoreExit     func()
+		restoreStdout   func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+		output = &bytes.Buffer{}
+		restoreStdout = SetStdout(output)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.GetAppReturns(plugin_models.GetAppModel{
+			Name: "myapp",
+			Routes: []plugin_models.GetApp_RouteSummary{
+				{Host: "myapp", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+			},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreStdout()
+		restoreExit()
+	})
+
+	Context("when push-zdd is given --dry-run", func() {
+		BeforeEach(func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--dry-run", "--health-timeout", "1m"})
+		})
+
+		It("then it should not touch the foundation", func() {
+			Ω(exitCode).Should(Equal(0))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+
+		It("then it should print every step it would take", func() {
+			Ω(output.String()).Should(ContainSubstring(`plan for myapp using the manifest strategy:
+  1. rename: rename myapp to myapp-venerable
+  2. push: cf push myapp
+  3. verify: wait up to 1m0s for every instance of myapp to have been running for 10s
+  4. delete: delete myapp-venerable
+`))
+		})
+	})
+
+	Context("when push-zdd is given --dry-run and --output json", func() {
+		It("then it should print the plan as json", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--dry-run", "--output", "json", "--strategy", "blue-green"})
+			Ω(exitCode).Should(Equal(0))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+
+			var plan struct {
+				App      string
+				Strategy string
+				Steps    []struct {
+					Name        string
+					Description string
+					Reversible  bool
+				}
+			}
+			Ω(json.NewDecoder(output).Decode(&plan)).Should(Succeed())
+			Ω(plan.App).Should(Equal("myapp"))
+			Ω(plan.Strategy).Should(Equal("blue-green"))
+			Ω(plan.Steps[0].Name).Should(Equal("push-green"))
+			Ω(plan.Steps[0].Description).Should(Equal("cf push myapp-green --no-route"))
+			Ω(plan.Steps[0].Reversible).Should(BeTrue())
+			Ω(plan.Steps[3].Name).Should(Equal("map-routes"))
+			Ω(plan.Steps[3].Description).Should(Equal("map myapp.example.com to myapp-green"))
+		})
+	})
+
+	Context("when the pre-flight checks fail", func() {
+		It("then it should fail without printing a plan", func() {
+			cliConn.IsLoggedInReturns(false, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--dry-run"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(output.String()).ShouldNot(ContainSubstring("plan for"))
+		})
+	})
+})
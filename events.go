@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//types of the json objects written by --output json
+const (
+	planEvent     = "plan"
+	stepEvent     = "step"
+	rollbackEvent = "rollback"
+	undoEvent     = "undo"
+	summaryEvent  = "summary"
+)
+
+//how a step, an app's deployment or a whole run of push-zdd turned out
+const (
+	outcomeStarted        = "started"
+	outcomeRetrying       = "retrying"
+	outcomeSucceeded      = "succeeded"
+	outcomePlanned        = "planned"
+	outcomeFailed         = "failed"
+	outcomePreflight      = "preflight_failed"
+	outcomeRolledBack     = "rolled_back"
+	outcomeRollbackFailed = "rollback_failed"
+	outcomeTimedOut       = "timed_out"
+	outcomeCancelled      = "cancelled"
+)
+
+//event - a line of the json output, describing one thing which happened
+//during a deployment
+type event struct {
+	Type        string    `json:"type"`
+	Time        time.Time `json:"time"`
+	App         string    `json:"app"`
+	Step        string    `json:"step,omitempty"`
+	Number      int       `json:"number,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Outcome     string    `json:"outcome"`
+	Attempt     int       `json:"attempt,omitempty"`
+	Duration    float64   `json:"duration_seconds,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+//summary - the last line of the json output, how the run went as a whole
+type summary struct {
+	Type     string       `json:"type"`
+	Time     time.Time    `json:"time"`
+	Outcome  string       `json:"outcome"`
+	ExitCode int          `json:"exit_code"`
+	Duration float64      `json:"duration_seconds"`
+	Error    string       `json:"error,omitempty"`
+	Apps     []appSummary `json:"apps"`
+}
+
+//appSummary - how the deployment of one app went
+type appSummary struct {
+	App      string    `json:"app"`
+	Outcome  string    `json:"outcome"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Duration float64   `json:"duration_seconds"`
+	Error    string    `json:"error,omitempty"`
+}
+
+//runSummary - what is gathered while push-zdd runs for its summary
+type runSummary struct {
+	started time.Time
+	apps    []appOutcome
+}
+
+//summarisedError - an error which has already been written to the json
+//output as part of the summary
+type summarisedError struct {
+	error
+}
+
+//Unwrap - the error which was summarised
+func (e summarisedError) Unwrap() error {
+	return e.error
+}
+
+//jsonObserver - writes each step of a deployment to stdout as a json event,
+//while the console progress goes to stderr
+type jsonObserver struct {
+	consoleObserver
+}
+
+//StepStarted - write a started event
+func (o jsonObserver) StepStarted(index int, action rewind.Action) {
+	o.consoleObserver.StepStarted(index, action)
+	o.emit(stepEvent, index, action, outcomeStarted, nil, 0)
+}
+
+//StepSucceeded - write a succeeded event
+func (o jsonObserver) StepSucceeded(index int, action rewind.Action, duration time.Duration) {
+	o.consoleObserver.StepSucceeded(index, action, duration)
+	o.emit(stepEvent, index, action, outcomeSucceeded, nil, duration)
+}
+
+//StepFailed - write a failed event, or timed_out when the step ran out of time
+func (o jsonObserver) StepFailed(index int, action rewind.Action, err error, duration time.Duration) {
+	o.consoleObserver.StepFailed(index, action, err, duration)
+	outcome := outcomeFailed
+	if _, ok := err.(*rewind.TimeoutError); ok {
+		outcome = outcomeTimedOut
+	}
+	o.emit(stepEvent, index, action, outcome, err, duration)
+}
+
+//StepRetrying - write a retrying event for the attempt which failed
+func (o jsonObserver) StepRetrying(index int, action rewind.Action, err error, attempt int, wait time.Duration) {
+	o.consoleObserver.StepRetrying(index, action, err, attempt, wait)
+	e := o.event(stepEvent, index, action, outcomeRetrying, err, 0)
+	e.Attempt = attempt
+	o.write(e)
+}
+
+//RollbackStarted - write a rollback started event
+func (o jsonObserver) RollbackStarted(err error) {
+	o.consoleObserver.RollbackStarted(err)
+	o.write(event{Type: rollbackEvent, Outcome: outcomeStarted, Error: errorString(err)})
+}
+
+//StepReversed - write an undo event for the step
+func (o jsonObserver) StepReversed(index int, action rewind.Action, err error, duration time.Duration) {
+	o.consoleObserver.StepReversed(index, action, err, duration)
+	outcome := outcomeSucceeded
+	if err != nil {
+		outcome = outcomeFailed
+	}
+	o.emit(undoEvent, index, action, outcome, err, duration)
+}
+
+//RollbackFinished - write a rolled_back or rollback_failed event
+func (o jsonObserver) RollbackFinished(rewindErr *rewind.RewindError, duration time.Duration) {
+	o.consoleObserver.RollbackFinished(rewindErr, duration)
+	e := event{Type: rollbackEvent, Outcome: outcomeRolledBack, Duration: duration.Seconds()}
+	if !rewindErr.RolledBack() {
+		e.Outcome = outcomeRollbackFailed
+		e.Error = rewindErr.Error()
+	}
+	o.write(e)
+}
+
+func (o jsonObserver) emit(eventType string, index int, action rewind.Action, outcome string, err error, duration time.Duration) {
+	o.write(o.event(eventType, index, action, outcome, err, duration))
+}
+
+func (o jsonObserver) event(eventType string, index int, action rewind.Action, outcome string, err error, duration time.Duration) event {
+	return event{
+		Type:        eventType,
+		Step:        action.Name,
+		Number:      index + 1,
+		Description: action.Description,
+		Outcome:     outcome,
+		Duration:    duration.Seconds(),
+		Error:       errorString(err),
+	}
+}
+
+//write - stamp e with the time and app, then write it as a line of json
+func (o jsonObserver) write(e event) {
+	e.Time = time.Now().UTC()
+	e.App = o.plugin.appName
+	json.NewEncoder(stdout).Encode(e)
+}
+
+//writeSummary - write the summary of the run which returned err, returning
+//err marked as summarised so it is not mistaken for output
+func (plugin AutopilotPlugin) writeSummary(err error) error {
+	s := summary{
+		Type:     summaryEvent,
+		Time:     time.Now().UTC(),
+		Outcome:  outcomeOf(err, plugin.options.dryRun),
+		ExitCode: exitCode(err),
+		Duration: time.Since(plugin.summary.started).Seconds(),
+		Error:    errorString(err),
+		Apps:     []appSummary{},
+	}
+
+	for _, outcome := range plugin.summary.apps {
+		s.Apps = append(s.Apps, appSummary{
+			App:      outcome.appName,
+			Outcome:  outcomeOf(outcome.err, plugin.options.dryRun),
+			Started:  outcome.started.UTC(),
+			Finished: outcome.finished.UTC(),
+			Duration: outcome.finished.Sub(outcome.started).Seconds(),
+			Error:    errorString(outcome.err),
+		})
+	}
+
+	json.NewEncoder(stdout).Encode(s)
+	if err != nil {
+		return summarisedError{err}
+	}
+	return nil
+}
+
+//outcomeOf - how a deployment which returned err turned out
+func outcomeOf(err error, dryRun bool) string {
+	switch e := err.(type) {
+	case nil:
+		if dryRun {
+			return outcomePlanned
+		}
+		return outcomeSucceeded
+	case *preflightError:
+		return outcomePreflight
+	case *rewind.RewindError:
+		switch e.Kind() {
+		case rewind.RollbackFailed:
+			return outcomeRollbackFailed
+		case rewind.Cancelled:
+			return outcomeCancelled
+		case rewind.TimedOut:
+			return outcomeTimedOut
+		}
+		return outcomeRolledBack
+	}
+	return outcomeFailed
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
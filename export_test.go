@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/xchapter7x/autopilot/application_repo"
+)
+
+//SetExit - replace the process exit used by fatalIf, returns a func restoring it
+func SetExit(f func(int)) (restore func()) {
+	exit = f
+	return func() {
+		exit = os.Exit
+	}
+}
+
+//SetCancelSignals - replace the signals trapped during a deployment, returns a
+//func restoring them
+func SetCancelSignals(signals ...os.Signal) (restore func()) {
+	original := cancelSignals
+	cancelSignals = signals
+	return func() {
+		cancelSignals = original
+	}
+}
+
+//SetHealthPollInterval - replace the time waited between health checks,
+//returns a func restoring it
+func SetHealthPollInterval(interval time.Duration) (restore func()) {
+	original := healthPollInterval
+	healthPollInterval = interval
+	return func() {
+		healthPollInterval = original
+	}
+}
+
+//SetAbandonGrace - replace how long a stopped step is given to finish before
+//it is undone, returns a func restoring it
+func SetAbandonGrace(grace time.Duration) (restore func()) {
+	original := abandonGrace
+	abandonGrace = grace
+	return func() {
+		abandonGrace = original
+	}
+}
+
+//SetSmokeInterval - replace the time waited between smoke test requests,
+//returns a func restoring it
+func SetSmokeInterval(interval time.Duration) (restore func()) {
+	original := smokeInterval
+	smokeInterval = interval
+	return func() {
+		smokeInterval = original
+	}
+}
+
+//SetStdout - replace where plans and progress are written, returns a func
+//restoring it
+func SetStdout(w io.Writer) (restore func()) {
+	original := stdout
+	stdout = w
+	return func() {
+		stdout = original
+	}
+}
+
+//SetApplicationRepo - make commands use repo in place of one talking to the
+//cli connection, returns a func restoring it
+func SetApplicationRepo(repo application_repo.Repo) (restore func()) {
+	original := newRepo
+	newRepo = func(plugin.CliConnection, options) application_repo.Repo {
+		return repo
+	}
+	return func() {
+		newRepo = original
+	}
+}
+
+//WatchApplicationRepo - keep the repo each command creates, returns the repos
+//created so far and a func to stop watching
+func WatchApplicationRepo() (created func() []application_repo.Repo, restore func()) {
+	original := newRepo
+	repos := []application_repo.Repo{}
+	newRepo = func(conn plugin.CliConnection, opts options) application_repo.Repo {
+		repo := original(conn, opts)
+		repos = append(repos, repo)
+		return repo
+	}
+	return func() []application_repo.Repo {
+			return repos
+		}, func() {
+			newRepo = original
+		}
+}
@@ -0,0 +1,103 @@
+package main_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+)
+
+var _ = Describe("Deploying to a foundation", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		foundation      *repofakes.FakeFoundation
+		autopilotPlugin *AutopilotPlugin
+		manifestPath    string
+		exitCode        int
+		restores        []func()
+	)
+
+	BeforeEach(func() {
+		foundation = repofakes.NewFakeFoundation()
+		foundation.AddApp(repofakes.FakeApp{
+			Name:   "myapp",
+			Routes: []string{"myapp.example.com", "www.example.com"},
+		})
+
+		manifestFile, err := ioutil.TempFile("", "manifest")
+		Ω(err).ShouldNot(HaveOccurred())
+		manifestFile.WriteString("applications:\n- name: myapp\n")
+		manifestFile.Close()
+		manifestPath = manifestFile.Name()
+
+		restores = []func(){
+			recordExit(&exitCode),
+			SetApplicationRepo(foundation),
+		}
+
+		cliConn = newCliConnection()
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		for _, restore := range restores {
+			restore()
+		}
+		os.Remove(manifestPath)
+	})
+
+	Context("when the new version deploys", func() {
+		It("then only the new version should be left, on every route", func() {
+			original := foundation.App("myapp").Guid
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(foundation.App("myapp").Guid).ShouldNot(Equal(original))
+			Ω(foundation.App("myapp").Started).Should(BeTrue())
+		})
+	})
+
+	Context("when the push fails", func() {
+		It("then the original app should be put back as it was", func() {
+			original := *foundation.App("myapp")
+			foundation.FailOn("PushApplication", "myapp", errors.New("staging failed"), 0)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(*foundation.App("myapp")).Should(Equal(original))
+		})
+	})
+
+	Context("when the new version is deployed blue-green", func() {
+		It("then the original app's routes should move to it", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "blue-green", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(foundation.App("myapp").Routes).Should(ConsistOf("myapp.example.com", "www.example.com"))
+		})
+	})
+
+	Context("when the new version's instances crash", func() {
+		It("then the original app should still be serving its routes", func() {
+			restores = append(restores, SetHealthPollInterval(0))
+			original := foundation.App("myapp").Guid
+			foundation.CrashOnStart("myapp", 1)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath, "--health-timeout", "10ms"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(foundation.App("myapp").Guid).Should(Equal(original))
+			Ω(foundation.App("myapp").Routes).Should(ConsistOf("myapp.example.com", "www.example.com"))
+		})
+	})
+})
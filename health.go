@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//verifyStep - the name of the health gate step in the deployment journal
+const verifyStep = "verify"
+
+//healthPollInterval - how long to wait between checks of an app's health
+var healthPollInterval = 2 * time.Second
+
+//getVerificationActions - the checks the new version, appName, must pass
+//before the old one, which is called oldAppName at that point, is retired.
+//Only its service bindings if cf push was told not to start it
+func (plugin AutopilotPlugin) getVerificationActions(appName, oldAppName string, argList []string) (actionList []rewind.Action) {
+	if required := plugin.requiredServices(); len(required) > 0 {
+		actionList = append(actionList, plugin.journaled(verifyServicesStep, plugin.getVerifyServicesAction(appName, required)))
+	}
+	if !isStarting(argList) {
+		return
+	}
+
+	actionList = append(actionList, plugin.journaled(verifyStep, plugin.getVerifyAction(appName)))
+	if plugin.options.smokeURL != "" {
+		actionList = append(actionList, plugin.journaled(smokeStep, plugin.getSmokeTestAction(appName)))
+	}
+	if plugin.options.smokeCmd != "" {
+		actionList = append(actionList, plugin.journaled(smokeCmdStep, plugin.getSmokeCommandAction(appName, oldAppName)))
+	}
+	return
+}
+
+//getVerifyAction - wait for every instance of the app to be running, and to
+//have stayed running for the health window, failing if that does not happen
+//before the health timeout
+func (plugin AutopilotPlugin) getVerifyAction(appName string) rewind.Action {
+	return rewind.Action{
+		Description: fmt.Sprintf("wait up to %s for every instance of %s to have been running for %s",
+			plugin.options.healthTimeout, appName, plugin.options.healthWindow),
+		Retry: noRetry,
+		Forward: func() error {
+			deadline := time.Now().Add(plugin.options.healthTimeout)
+			for {
+				app, err := plugin.appRepo.GetApplication(appName)
+				if err != nil {
+					return err
+				}
+
+				reason := unhealthyReason(app, plugin.options.healthWindow)
+				if reason == "" {
+					return nil
+				}
+
+				if !time.Now().Before(deadline) {
+					return fmt.Errorf("%s did not become healthy within %s: %s", appName, plugin.options.healthTimeout, reason)
+				}
+				time.Sleep(healthPollInterval)
+			}
+		},
+	}
+}
+
+//unhealthyReason - describe why the app is not yet healthy, or "" if all of
+//its instances have been running for at least window
+func unhealthyReason(app plugin_models.GetAppModel, window time.Duration) string {
+	if app.RunningInstances != app.InstanceCount {
+		return fmt.Sprintf("%d of %d instances are running", app.RunningInstances, app.InstanceCount)
+	}
+
+	for i, instance := range app.Instances {
+		if !strings.EqualFold(instance.State, "running") {
+			return fmt.Sprintf("instance %d is %s", i, strings.ToLower(instance.State))
+		}
+
+		if up := time.Since(instance.Since); up < window {
+			return fmt.Sprintf("instance %d has only been running for %s", i, up.Round(time.Second))
+		}
+	}
+	return ""
+}
+
+//isStarting - false when cf push was told not to start the app
+func isStarting(argList []string) bool {
+	for _, arg := range argList {
+		if arg == "--no-start" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package main_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Health gate", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		newApp          plugin_models.GetAppModel
+		pushed          bool
+		newAppReads     int
+		exitCode        int
+		restoreExit     func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		newApp = plugin_models.GetAppModel{
+			Name:             "myapp",
+			InstanceCount:    2,
+			RunningInstances: 2,
+			Instances: []plugin_models.GetApp_AppInstanceFields{
+				{State: "RUNNING", Since: time.Now().Add(-time.Hour)},
+				{State: "RUNNING", Since: time.Now().Add(-time.Hour)},
+			},
+		}
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		pushed, newAppReads = false, 0
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				pushed = true
+			}
+			return nil, nil
+		}
+		cliConn.GetAppStub = func(string) (plugin_models.GetAppModel, error) {
+			if pushed {
+				newAppReads++
+			}
+			return newApp, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+	})
+
+	run := func(extraArgs ...string) {
+		args := []string{"push-zdd", "myapp", "--health-timeout", "0s", "--health-window", "1m"}
+		autopilotPlugin.Run(cliConn, append(args, extraArgs...))
+	}
+
+	Context("when every instance of the new version has been running for the window", func() {
+		It("then it should delete the venerable app", func() {
+			run()
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cliConn.GetAppArgsForCall(0)).Should(Equal("myapp"))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"delete", "myapp-venerable", "-f"},
+			}))
+		})
+	})
+
+	Context("when an instance of the new version has crashed", func() {
+		It("then it should roll back instead of deleting the venerable app", func() {
+			newApp.RunningInstances = 1
+			newApp.Instances[1].State = "CRASHED"
+			run()
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"delete", "myapp", "-f"},
+				{"rename", "myapp-venerable", "myapp"},
+			}))
+		})
+	})
+
+	Context("when an instance has restarted within the window", func() {
+		It("then it should roll back", func() {
+			newApp.Instances[0].Since = time.Now()
+			run()
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp", "-f"}))
+		})
+	})
+
+	Context("when the new version becomes healthy while being polled", func() {
+		It("then it should wait for it", func() {
+			restorePoll := SetHealthPollInterval(time.Millisecond)
+			defer restorePoll()
+
+			cliConn.GetAppStub = func(string) (plugin_models.GetAppModel, error) {
+				if !pushed {
+					return newApp, nil
+				}
+				newAppReads++
+				if newAppReads < 3 {
+					return plugin_models.GetAppModel{InstanceCount: 2}, nil
+				}
+				return newApp, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--health-timeout", "1m"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(newAppReads).Should(Equal(3))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp-venerable", "-f"}))
+		})
+	})
+
+	Context("when the app is pushed without being started", func() {
+		It("then it should not wait for it to become healthy", func() {
+			newApp.RunningInstances = 0
+			run("--no-start")
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(newAppReads).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/manifest"
+)
+
+//routeFlags - cf push flags which decide the new version's routes, so routes
+//are not inherited when one of them is given
+var routeFlags = []string{"-n", "--hostname", "-d", "--no-hostname", "--no-route", "--random-route", "--route-path"}
+
+//inheritFromCurrent - when --inherit-from-current is given, fill in whatever
+//the manifest leaves out of the new version from the app it replaces. Returns
+//the args to push with and a func removing the manifest written for them
+func (plugin *AutopilotPlugin) inheritFromCurrent(argList []string) ([]string, func(), error) {
+	cleanup := func() {}
+	if !plugin.options.inheritCurrent {
+		return argList, cleanup, nil
+	}
+
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil || !contains(apps, plugin.appName) {
+		return argList, cleanup, err
+	}
+
+	current, err := plugin.appRepo.GetApplication(plugin.appName)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	m := plugin.manifest
+	if m == nil {
+		m = manifest.New(plugin.appName)
+	}
+
+	inherited := m.Inherit(plugin.appName, currentApplication(current, argList))
+	if len(inherited) == 0 {
+		return argList, cleanup, nil
+	}
+	plugin.manifest = m
+	plugin.printf("inheriting %s from the current %s\n", strings.Join(inherited, ", "), plugin.appName)
+
+	path, err := writeExpandedManifest(m)
+	if err != nil {
+		return nil, cleanup, err
+	}
+	cleanup = func() {
+		os.Remove(path)
+	}
+	return withFlag(withoutFlag(argList, "--no-manifest"), "-f", path), cleanup, nil
+}
+
+//currentApplication - the running app described as a manifest would, leaving
+//out its routes when argList decides the new version's routes itself
+func currentApplication(app plugin_models.GetAppModel, argList []string) manifest.Application {
+	current := manifest.Application{
+		Instances: app.InstanceCount,
+		Env:       map[string]string{},
+	}
+	if app.Memory > 0 {
+		current.Memory = fmt.Sprintf("%dM", app.Memory)
+	}
+	if app.DiskQuota > 0 {
+		current.DiskQuota = fmt.Sprintf("%dM", app.DiskQuota)
+	}
+
+	for name, value := range app.EnvironmentVars {
+		current.Env[name] = envValue(value)
+	}
+	for _, service := range app.Services {
+		current.Services = append(current.Services, service.Name)
+	}
+
+	for _, flag := range routeFlags {
+		if hasFlag(argList, flag) {
+			return current
+		}
+	}
+	for _, route := range app.Routes {
+		current.Routes = append(current.Routes, manifest.Route{Route: describeRoutes(route)})
+	}
+	return current
+}
+
+//envValue - an environment variable as cf would set it, values which are not
+//strings are set as json
+func envValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+//withoutFlag - args with every occurrence of a flag which takes no value removed
+func withoutFlag(args []string, flag string) []string {
+	result := []string{}
+	for _, arg := range args {
+		if arg != flag {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	"github.com/xchapter7x/autopilot/manifest"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Inheriting from the current app", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		manifestDir     string
+		pushedManifest  *manifest.Manifest
+		pushedArgs      []string
+		exitCode        int
+		restoreExit     func()
+	)
+
+	write := func(content string) string {
+		path := filepath.Join(manifestDir, "manifest.yml")
+		Ω(ioutil.WriteFile(path, []byte(content), 0600)).Should(Succeed())
+		return path
+	}
+
+	pushedApp := func() manifest.Application {
+		Ω(pushedManifest).ShouldNot(BeNil())
+		app, ok := pushedManifest.Application("myapp")
+		Ω(ok).Should(BeTrue())
+		return app
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		var err error
+		manifestDir, err = ioutil.TempDir("", "autopilot-inherit")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		running := plugin_models.GetApp_AppInstanceFields{State: "running", Since: time.Now().Add(-time.Hour)}
+		pushedManifest, pushedArgs = nil, nil
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.GetAppReturns(plugin_models.GetAppModel{
+			Name:             "myapp",
+			State:            "started",
+			InstanceCount:    2,
+			RunningInstances: 2,
+			Memory:           2048,
+			DiskQuota:        512,
+			EnvironmentVars:  map[string]interface{}{"REGION": "eu", "WORKERS": float64(4)},
+			Instances:        []plugin_models.GetApp_AppInstanceFields{running, running},
+			Services:         []plugin_models.GetApp_ServiceSummary{{Name: "db"}},
+			Routes: []plugin_models.GetApp_RouteSummary{
+				{Host: "myapp", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+				{Host: "www", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+			},
+		}, nil)
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				pushedArgs = args
+				for i := range args[:len(args)-1] {
+					if args[i] == "-f" {
+						pushedManifest, err = manifest.Load(args[i+1], nil)
+						Ω(err).ShouldNot(HaveOccurred())
+					}
+				}
+			}
+			return nil, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(manifestDir)
+		restoreExit()
+	})
+
+	Context("when the manifest leaves out the app's runtime state", func() {
+		It("then it should push the current app's state along with the manifest", func() {
+			path := write("applications:\n- name: myapp\n  memory: 1G\n  env:\n    REGION: us\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path, "--inherit-from-current"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedArgs).ShouldNot(ContainElement(path))
+			Ω(pushedApp()).Should(Equal(manifest.Application{
+				Name:      "myapp",
+				Instances: 2,
+				Memory:    "1G",
+				DiskQuota: "512M",
+				Env:       map[string]string{"REGION": "us", "WORKERS": "4"},
+				Services:  []string{"db"},
+				Routes:    []manifest.Route{{Route: "myapp.example.com"}, {Route: "www.example.com"}},
+			}))
+		})
+	})
+
+	Context("when there is no manifest", func() {
+		It("then it should push the current app's state in a manifest of its own", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--no-manifest", "-p", "app.jar", "--inherit-from-current"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedArgs).ShouldNot(ContainElement("--no-manifest"))
+			Ω(pushedArgs).Should(ContainElement("app.jar"))
+			Ω(pushedApp().Instances).Should(Equal(2))
+			Ω(pushedApp().Memory).Should(Equal("2048M"))
+		})
+	})
+
+	Context("when cf push is told which route to use", func() {
+		It("then it should not inherit the current app's routes", func() {
+			path := write("applications:\n- name: myapp\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path, "-n", "myapp-v2", "--inherit-from-current"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedApp().Routes).Should(BeEmpty())
+			Ω(pushedApp().Instances).Should(Equal(2))
+		})
+	})
+
+	Context("when inheriting is not asked for", func() {
+		It("then it should push the manifest as it is", func() {
+			path := write("applications:\n- name: myapp\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedArgs).Should(Equal([]string{"push", "myapp", "-f", path}))
+		})
+	})
+})
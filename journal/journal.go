@@ -0,0 +1,184 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Outcomes recorded against a step
+const (
+	Started       = "started"
+	Succeeded     = "succeeded"
+	Failed        = "failed"
+	Reversed      = "reversed"
+	ReverseFailed = "reverse-failed"
+	Baking        = "baking"
+)
+
+//DeploymentStep - the step name which marks the start and end of a deployment
+const DeploymentStep = "deployment"
+
+//Entry - a single transition of a step in a deployment
+type Entry struct {
+	App     string    `json:"app"`
+	Step    string    `json:"step"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+	//Expires - when whatever the step left behind should be cleaned up
+	Expires *time.Time `json:"expires,omitempty"`
+	//Metadata - what the step needs to be undone later, such as how many
+	//instances an app had before it was scaled down
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+//Journal - an append-only record of the deployments of an application
+type Journal struct {
+	path string
+}
+
+//New - constructor function to create the journal of appName inside dir
+func New(dir, appName string) *Journal {
+	return &Journal{
+		path: filepath.Join(dir, appName+".journal"),
+	}
+}
+
+//DefaultDir - the directory journals are kept in, AUTOPILOT_JOURNAL_DIR if it
+//is set, otherwise alongside the cf cli's own config
+func DefaultDir() string {
+	if dir := os.Getenv("AUTOPILOT_JOURNAL_DIR"); dir != "" {
+		return dir
+	}
+
+	home := os.Getenv("CF_HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	return filepath.Join(home, ".cf", "autopilot")
+}
+
+//TargetDir - the directory inside dir for the journals of one space, named
+//after the api endpoint's host, the org and the space, so apps of the same
+//name in other spaces or on other foundations have journals of their own
+func TargetDir(dir, endpoint, org, space string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		endpoint = u.Host
+	}
+	return filepath.Join(dir, pathSafe(endpoint), pathSafe(org), pathSafe(space))
+}
+
+//pathSafe - name as a single path element which stays inside its directory
+func pathSafe(name string) string {
+	name = strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+	if name == "." || name == ".." {
+		return "_" + name
+	}
+	return name
+}
+
+//Path - the file the journal is written to
+func (j *Journal) Path() string {
+	return j.path
+}
+
+//Record - append entry to the journal, stamping it with the current time if
+//it has none
+func (j *Journal) Record(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+//Entries - every entry in the journal, oldest first
+func (j *Journal) Entries() (entries []Entry, err error) {
+	file, err := os.Open(j.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+//Latest - the entries of the most recent deployment in the journal
+func (j *Journal) Latest() (Deployment, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	start := 0
+	for i, entry := range entries {
+		if entry.Step == DeploymentStep && entry.Outcome == Started {
+			start = i
+		}
+	}
+	return Deployment(entries[start:]), nil
+}
+
+//Deployment - the entries recorded by a single deployment
+type Deployment []Entry
+
+//Outcome - the last outcome recorded for step, or "" if it never started
+func (d Deployment) Outcome(step string) (outcome string) {
+	for _, entry := range d {
+		if entry.Step == step {
+			outcome = entry.Outcome
+		}
+	}
+	return
+}
+
+//Find - the last entry recorded for step with outcome
+func (d Deployment) Find(step, outcome string) (found Entry, ok bool) {
+	for _, entry := range d {
+		if entry.Step == step && entry.Outcome == outcome {
+			found, ok = entry, true
+		}
+	}
+	return
+}
+
+//Metadata - the value of key in the last entry recorded for step which has it
+func (d Deployment) Metadata(step, key string) (value string, ok bool) {
+	for _, entry := range d {
+		if v, found := entry.Metadata[key]; entry.Step == step && found {
+			value, ok = v, true
+		}
+	}
+	return
+}
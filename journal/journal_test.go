@@ -0,0 +1,113 @@
+package journal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/xchapter7x/autopilot/journal"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		dir string
+		j   *Journal
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "journal")
+		Ω(err).ShouldNot(HaveOccurred())
+		j = New(filepath.Join(dir, "nested"), "myapp")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("Record", func() {
+		It("appends entries which can be read back in order", func() {
+			Ω(j.Record(Entry{App: "myapp", Step: "rename", Outcome: Started})).Should(Succeed())
+			Ω(j.Record(Entry{App: "myapp", Step: "rename", Outcome: Failed, Error: "no app"})).Should(Succeed())
+
+			entries, err := j.Entries()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(entries).Should(HaveLen(2))
+			Ω(entries[0].Outcome).Should(Equal(Started))
+			Ω(entries[1].Outcome).Should(Equal(Failed))
+			Ω(entries[1].Error).Should(Equal("no app"))
+			Ω(entries[1].Time.IsZero()).Should(BeFalse())
+		})
+
+		It("writes to a file named after the app", func() {
+			Ω(j.Path()).Should(Equal(filepath.Join(dir, "nested", "myapp.journal")))
+		})
+	})
+
+	Describe("TargetDir", func() {
+		It("keeps the journals of each foundation, org and space apart", func() {
+			Ω(TargetDir(dir, "https://api.example.com", "my-org", "my-space")).Should(Equal(filepath.Join(dir, "api.example.com", "my-org", "my-space")))
+			Ω(TargetDir(dir, "https://api.example.com", "my-org", "other")).ShouldNot(Equal(TargetDir(dir, "https://api.example.com", "my-org", "my-space")))
+		})
+
+		It("does not let a name leave the directory", func() {
+			Ω(TargetDir(dir, "https://api.example.com", "..", "a/b")).Should(Equal(filepath.Join(dir, "api.example.com", "_..", "a_b")))
+		})
+	})
+
+	Describe("Latest", func() {
+		It("returns only the entries of the most recent deployment", func() {
+			Ω(j.Record(Entry{Step: DeploymentStep, Outcome: Started})).Should(Succeed())
+			Ω(j.Record(Entry{Step: "rename", Outcome: Succeeded})).Should(Succeed())
+			Ω(j.Record(Entry{Step: DeploymentStep, Outcome: Succeeded})).Should(Succeed())
+			Ω(j.Record(Entry{Step: DeploymentStep, Outcome: Started})).Should(Succeed())
+			Ω(j.Record(Entry{Step: "push", Outcome: Started})).Should(Succeed())
+
+			deployment, err := j.Latest()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(deployment).Should(HaveLen(2))
+			Ω(deployment.Outcome("rename")).Should(Equal(""))
+			Ω(deployment.Outcome("push")).Should(Equal(Started))
+		})
+
+		It("finds the last entry of a step with an outcome", func() {
+			expires := time.Now().Add(time.Hour).UTC().Round(time.Second)
+			Ω(j.Record(Entry{Step: DeploymentStep, Outcome: Started})).Should(Succeed())
+			Ω(j.Record(Entry{Step: "bake", Outcome: Baking, Expires: &expires})).Should(Succeed())
+			Ω(j.Record(Entry{Step: "bake", Outcome: Succeeded})).Should(Succeed())
+
+			deployment, err := j.Latest()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			entry, ok := deployment.Find("bake", Baking)
+			Ω(ok).Should(BeTrue())
+			Ω(entry.Expires.Equal(expires)).Should(BeTrue())
+
+			_, ok = deployment.Find("bake", Failed)
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("finds the last metadata recorded for a step", func() {
+			j.Record(Entry{App: "myapp", Step: DeploymentStep, Outcome: Started})
+			j.Record(Entry{App: "myapp", Step: "rename", Outcome: Started, Metadata: map[string]string{"instances": "4"}})
+			j.Record(Entry{App: "myapp", Step: "rename", Outcome: Succeeded, Metadata: map[string]string{"instances": "6"}})
+			j.Record(Entry{App: "myapp", Step: "push", Outcome: Succeeded})
+
+			deployment, err := j.Latest()
+			Ω(err).ShouldNot(HaveOccurred())
+			value, ok := deployment.Metadata("rename", "instances")
+			Ω(ok).Should(BeTrue())
+			Ω(value).Should(Equal("6"))
+			_, ok = deployment.Metadata("push", "instances")
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("returns an error when there is no journal", func() {
+			_, err := j.Latest()
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+	})
+})
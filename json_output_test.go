@@ -0,0 +1,153 @@
+package main_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("JSON output", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		output          *bytes.Buffer
+		exitCode        int
+		restoreExit     func()
+		restoreStdout   func()
+	)
+
+	type line struct {
+		Type     string
+		App      string
+		Step     string
+		Number   int
+		Outcome  string
+		Error    string
+		ExitCode int `json:"exit_code"`
+		Apps     []struct {
+			App     string
+			Outcome string
+			Error   string
+		}
+	}
+
+	//lines - every line written to stdout, which must each be a json object
+	lines := func() (parsed []line) {
+		decoder := json.NewDecoder(output)
+		for decoder.More() {
+			var l line
+			Ω(decoder.Decode(&l)).Should(Succeed())
+			parsed = append(parsed, l)
+		}
+		return
+	}
+
+	outcomes := func(parsed []line) (described []string) {
+		for _, l := range parsed {
+			described = append(described, l.Type+" "+l.Step+" "+l.Outcome)
+		}
+		return
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+		output = &bytes.Buffer{}
+		restoreStdout = SetStdout(output)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreStdout()
+		restoreExit()
+	})
+
+	Context("when the deployment succeeds", func() {
+		It("then it should write an event for each step and a summary", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--no-start", "--output", "json"})
+			Ω(exitCode).Should(Equal(0))
+
+			parsed := lines()
+			Ω(outcomes(parsed)).Should(Equal([]string{
+				"step rename started",
+				"step rename succeeded",
+				"step push started",
+				"step push succeeded",
+				"step delete started",
+				"step delete succeeded",
+				"summary  succeeded",
+			}))
+			Ω(parsed[0].App).Should(Equal("myapp"))
+			Ω(parsed[2].Number).Should(Equal(2))
+
+			summary := parsed[len(parsed)-1]
+			Ω(summary.ExitCode).Should(Equal(0))
+			Ω(summary.Apps).Should(HaveLen(1))
+			Ω(summary.Apps[0].App).Should(Equal("myapp"))
+			Ω(summary.Apps[0].Outcome).Should(Equal("succeeded"))
+		})
+
+		It("then it should keep the output of cf off stdout", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--output", "json"})
+
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+			Ω(cliConn.CliCommandWithoutTerminalOutputArgsForCall(1)).Should(Equal([]string{"push", "myapp"}))
+		})
+	})
+
+	Context("when a step fails", func() {
+		It("then it should write the failure, the rollback and a summary", func() {
+			cliConn.CliCommandWithoutTerminalOutputStub = func(args ...string) ([]string, error) {
+				if args[0] == "push" {
+					return nil, errors.New("push failed")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--output", "json"})
+			Ω(exitCode).Should(Equal(3))
+
+			parsed := lines()
+			Ω(outcomes(parsed)).Should(Equal([]string{
+				"step rename started",
+				"step rename succeeded",
+				"step push started",
+				"step push failed",
+				"rollback  started",
+				"undo push succeeded",
+				"undo rename succeeded",
+				"rollback  rolled_back",
+				"summary  rolled_back",
+			}))
+			Ω(parsed[3].Error).Should(Equal("push failed"))
+
+			summary := parsed[len(parsed)-1]
+			Ω(summary.ExitCode).Should(Equal(3))
+			Ω(summary.Error).Should(Equal("push failed"))
+			Ω(summary.Apps[0].Outcome).Should(Equal("rolled_back"))
+		})
+	})
+
+	Context("when the pre-flight checks fail", func() {
+		It("then it should write only the summary", func() {
+			cliConn.IsLoggedInReturns(false, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--output", "json"})
+			Ω(exitCode).Should(Equal(2))
+
+			parsed := lines()
+			Ω(outcomes(parsed)).Should(Equal([]string{"summary  preflight_failed"}))
+			Ω(parsed[0].Error).Should(ContainSubstring("not logged in"))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/xchapter7x/autopilot/manifest"
+)
+
+//loadManifest - read and validate the manifest cf push will use, if there is
+//one, so a bad manifest is found before any app is touched. When inheritance
+//or variables changed it, the returned args push an expanded copy instead,
+//which cleanup removes
+func loadManifest(argList []string, opts options) (m *manifest.Manifest, args []string, cleanup func(), err error) {
+	cleanup = func() {}
+	usesVars := len(opts.varsFiles) > 0 || len(opts.vars) > 0
+
+	if hasFlag(argList, "--no-manifest") {
+		if usesVars {
+			return nil, nil, cleanup, errors.New("--vars-file and --var cannot be used with --no-manifest")
+		}
+		return nil, argList, cleanup, nil
+	}
+
+	vars, err := manifestVars(opts)
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+
+	path := manifestPath(argList)
+	m, err = manifest.Load(path, vars)
+	if os.IsNotExist(err) && !hasFlag(argList, "-f") && !usesVars {
+		return nil, argList, cleanup, nil
+	}
+	if os.IsNotExist(err) {
+		return nil, nil, cleanup, ErrNoManifest
+	}
+	if err != nil {
+		return nil, nil, cleanup, fmt.Errorf("could not read manifest %s: %s", path, err)
+	}
+
+	if err = m.Validate(); err != nil {
+		return nil, nil, cleanup, err
+	}
+
+	if !m.Expanded() {
+		return m, argList, cleanup, nil
+	}
+
+	expandedPath, err := writeExpandedManifest(m)
+	if err != nil {
+		return nil, nil, cleanup, err
+	}
+	cleanup = func() {
+		os.Remove(expandedPath)
+	}
+	return m, withFlag(argList, "-f", expandedPath), cleanup, nil
+}
+
+//writeExpandedManifest - write m out for cf push, returning where it went
+func writeExpandedManifest(m *manifest.Manifest) (string, error) {
+	data, err := m.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	file, err := ioutil.TempFile("", "autopilot-manifest-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err = file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+//manifestVars - the variables from every --vars-file, overridden by --var
+func manifestVars(opts options) (manifest.Vars, error) {
+	vars := manifest.Vars{}
+	for _, path := range opts.varsFiles {
+		fileVars, err := manifest.LoadVarsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range fileVars {
+			vars[name] = value
+		}
+	}
+
+	for name, value := range opts.vars {
+		vars[name] = manifest.ParseVar(value)
+	}
+	return vars, nil
+}
+
+//manifestPath - the manifest named by -f, or manifest.yml in the current
+//directory as cf push would use
+func manifestPath(argList []string) string {
+	path, ok := flagValue(argList, "-f")
+	if !ok {
+		path = "manifest.yml"
+	}
+
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "manifest.yml")
+	}
+	return path
+}
+
+//flagValue - the value given for flag in args, the last one wins
+func flagValue(args []string, flag string) (value string, ok bool) {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == flag {
+			value, ok = args[i+1], true
+		}
+	}
+	return
+}
@@ -0,0 +1,518 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+//Manifest - a cf application manifest with its inheritance, variables and
+//defaults resolved
+type Manifest struct {
+	Applications []Application
+
+	//raw - the resolved properties of each application, kept so the manifest
+	//can be written back out without losing anything the model leaves out
+	raw      []map[interface{}]interface{}
+	expanded bool
+}
+
+//Application - an application described by a manifest
+type Application struct {
+	Name                    string            `yaml:"name"`
+	Instances               int               `yaml:"instances"`
+	Memory                  string            `yaml:"memory"`
+	DiskQuota               string            `yaml:"disk_quota"`
+	Buildpack               string            `yaml:"buildpack"`
+	Command                 string            `yaml:"command"`
+	Path                    string            `yaml:"path"`
+	Stack                   string            `yaml:"stack"`
+	Routes                  []Route           `yaml:"routes"`
+	NoRoute                 bool              `yaml:"no-route"`
+	Services                []string          `yaml:"services"`
+	Env                     map[string]string `yaml:"env"`
+	HealthCheckType         string            `yaml:"health-check-type"`
+	HealthCheckHTTPEndpoint string            `yaml:"health-check-http-endpoint"`
+	Timeout                 int               `yaml:"timeout"`
+}
+
+//Route - a route an application should be mapped to
+type Route struct {
+	Route string `yaml:"route"`
+}
+
+//Vars - values for the ((variables)) in a manifest
+type Vars map[string]interface{}
+
+var variable = regexp.MustCompile(`\(\(([-\w./]+)\)\)`)
+
+//Load - read the manifest at path, following its inherit chain and replacing
+//its ((variables)) with vars
+func Load(path string, vars Vars) (*Manifest, error) {
+	node, inherited, err := load(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return resolve(node, filepath.Dir(path), vars, inherited)
+}
+
+//Parse - parse the contents of a manifest, any inherit is read relative to
+//the current directory
+func Parse(data []byte, vars Vars) (*Manifest, error) {
+	node, inherited, err := parse(data, ".", map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return resolve(node, ".", vars, inherited)
+}
+
+//LoadVarsFile - read the variables in a vars file
+func LoadVarsFile(path string) (Vars, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := Vars{}
+	if err = yaml.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("could not parse vars file %s: %s", path, err)
+	}
+	return vars, nil
+}
+
+//ParseVar - the value of a variable given on the command line, as the yaml
+//scalar it spells so numbers and booleans keep their type. Anything else,
+//such as a list or a map, is left as a string
+func ParseVar(value string) interface{} {
+	var scalar interface{}
+	if err := yaml.Unmarshal([]byte(value), &scalar); err != nil {
+		return value
+	}
+
+	switch scalar.(type) {
+	case int, int64, uint64, float64, bool:
+		return scalar
+	}
+	return value
+}
+
+//New - a manifest describing applications with only their names, for pushes
+//which were not given a manifest
+func New(names ...string) *Manifest {
+	m := &Manifest{}
+	for _, name := range names {
+		m.Applications = append(m.Applications, Application{Name: name})
+		m.raw = append(m.raw, map[interface{}]interface{}{"name": name})
+	}
+	return m
+}
+
+//AppNames - the names of every application in the manifest
+func (m *Manifest) AppNames() []string {
+	names := make([]string, len(m.Applications))
+	for i, app := range m.Applications {
+		names[i] = app.Name
+	}
+	return names
+}
+
+//Application - the application called name, a manifest describing a single
+//application describes whatever app it is pushed as
+func (m *Manifest) Application(name string) (Application, bool) {
+	i := m.index(name)
+	if i < 0 {
+		return Application{}, false
+	}
+
+	app := m.Applications[i]
+	app.Name = name
+	return app, true
+}
+
+//Inherit - fill in the instances, memory, disk quota, environment variables,
+//services and routes of the application called name from current wherever the
+//manifest leaves them out, returning the properties which were filled in. An
+//environment variable the manifest sets keeps its value, and routes are left
+//alone when the manifest routes the application any other way
+func (m *Manifest) Inherit(name string, current Application) (inherited []string) {
+	i := m.index(name)
+	if i < 0 {
+		return nil
+	}
+	app, properties := &m.Applications[i], m.raw[i]
+
+	set := func(key string, value interface{}) {
+		properties[key] = value
+		inherited = append(inherited, key)
+	}
+
+	if _, ok := properties["instances"]; !ok && current.Instances > 0 {
+		app.Instances = current.Instances
+		set("instances", current.Instances)
+	}
+	if _, ok := properties["memory"]; !ok && current.Memory != "" {
+		app.Memory = current.Memory
+		set("memory", current.Memory)
+	}
+	if _, ok := properties["disk_quota"]; !ok && current.DiskQuota != "" {
+		app.DiskQuota = current.DiskQuota
+		set("disk_quota", current.DiskQuota)
+	}
+
+	env, _ := properties["env"].(map[interface{}]interface{})
+	merged := map[interface{}]interface{}{}
+	for key, value := range current.Env {
+		if _, ok := env[key]; !ok {
+			merged[key] = value
+			if app.Env == nil {
+				app.Env = map[string]string{}
+			}
+			app.Env[key] = value
+		}
+	}
+	if len(merged) > 0 {
+		set("env", merge(merged, env))
+	}
+
+	if _, ok := properties["services"]; !ok && len(current.Services) > 0 {
+		app.Services = current.Services
+		set("services", current.Services)
+	}
+
+	if !routed(properties) && len(current.Routes) > 0 {
+		app.Routes = current.Routes
+		routes := []interface{}{}
+		for _, route := range current.Routes {
+			routes = append(routes, map[interface{}]interface{}{"route": route.Route})
+		}
+		set("routes", routes)
+	}
+
+	if len(inherited) > 0 {
+		m.expanded = true
+	}
+	return
+}
+
+//Only - a copy of the manifest describing just the application called name,
+//renamed to pushedAs, so cf push can push it under another name even when
+//the manifest describes several applications
+func (m *Manifest) Only(name, pushedAs string) *Manifest {
+	i := m.index(name)
+	if i < 0 {
+		return New(pushedAs)
+	}
+
+	app := m.Applications[i]
+	app.Name = pushedAs
+	properties := merge(m.raw[i], map[interface{}]interface{}{"name": pushedAs})
+	return &Manifest{
+		Applications: []Application{app},
+		raw:          []map[interface{}]interface{}{properties},
+		expanded:     true,
+	}
+}
+
+//Expanded - whether inheritance or variables made the manifest differ from
+//the file it was read from, in which case cf push needs the output of Marshal
+func (m *Manifest) Expanded() bool {
+	return m.expanded
+}
+
+//Marshal - the resolved manifest as yaml cf push understands, with each
+//application's defaults and inherited properties filled in
+func (m *Manifest) Marshal() ([]byte, error) {
+	return yaml.Marshal(map[string]interface{}{
+		"applications": m.raw,
+	})
+}
+
+//Validate - check the manifest describes applications cf push could deploy
+func (m *Manifest) Validate() error {
+	problems := []string{}
+	seen := map[string]bool{}
+
+	for i, app := range m.Applications {
+		label := app.Name
+		if app.Name == "" {
+			label = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("application %s has no name", label))
+		} else if seen[app.Name] {
+			problems = append(problems, fmt.Sprintf("application %s is described more than once", label))
+		}
+		seen[app.Name] = true
+
+		if app.Instances < 0 {
+			problems = append(problems, fmt.Sprintf("application %s: instances must not be negative", label))
+		}
+		if _, err := ParseMegabytes(app.Memory); app.Memory != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("application %s: memory %s", label, err))
+		}
+		if _, err := ParseMegabytes(app.DiskQuota); app.DiskQuota != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("application %s: disk_quota %s", label, err))
+		}
+
+		switch app.HealthCheckType {
+		case "", "port", "process", "http", "none":
+		default:
+			problems = append(problems, fmt.Sprintf("application %s: unknown health-check-type %q", label, app.HealthCheckType))
+		}
+		if app.HealthCheckHTTPEndpoint != "" && app.HealthCheckType != "http" {
+			problems = append(problems, fmt.Sprintf("application %s: health-check-http-endpoint needs health-check-type http", label))
+		}
+		if app.NoRoute && len(app.Routes) > 0 {
+			problems = append(problems, fmt.Sprintf("application %s: no-route cannot be used with routes", label))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid manifest: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+//index - where the application called name is in the manifest, -1 when it
+//is not there
+func (m *Manifest) index(name string) int {
+	for i, app := range m.Applications {
+		if app.Name == name {
+			return i
+		}
+	}
+	if len(m.Applications) == 1 {
+		return 0
+	}
+	return -1
+}
+
+//routed - whether properties say anything about an application's routes
+func routed(properties map[interface{}]interface{}) bool {
+	for _, key := range []string{"routes", "no-route", "random-route", "host", "hosts", "domain", "domains", "no-hostname"} {
+		if _, ok := properties[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+//ParseMegabytes - parse a memory or disk size such as 512M or 1G
+func ParseMegabytes(size string) (int, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := 0
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int
+	}{{"MB", 1}, {"GB", 1024}, {"TB", 1024 * 1024}, {"M", 1}, {"G", 1024}, {"T", 1024 * 1024}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSuffix(value, unit.suffix)
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.Atoi(value)
+	if multiplier == 0 || err != nil || n < 0 {
+		return 0, fmt.Errorf("must be a size such as 512M or 1G, got %q", size)
+	}
+	return n * multiplier, nil
+}
+
+//load - read the manifest at path merged over everything it inherits
+func load(path string, seen map[string]bool) (map[interface{}]interface{}, bool, error) {
+	absolute, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if seen[absolute] {
+		return nil, false, fmt.Errorf("manifest %s inherits itself", path)
+	}
+	seen[absolute] = true
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return parse(data, filepath.Dir(path), seen)
+}
+
+//parse - parse the contents of a manifest in dir merged over everything it
+//inherits
+func parse(data []byte, dir string, seen map[string]bool) (map[interface{}]interface{}, bool, error) {
+	node := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, false, err
+	}
+
+	parentPath, ok := node["inherit"].(string)
+	if !ok {
+		return node, false, nil
+	}
+	delete(node, "inherit")
+
+	if !filepath.IsAbs(parentPath) {
+		parentPath = filepath.Join(dir, parentPath)
+	}
+	parent, _, err := load(parentPath, seen)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not inherit %s: %s", parentPath, err)
+	}
+	return inherit(parent, node), true, nil
+}
+
+//inherit - merge child over parent, applications with the same name are
+//merged and the parent's applications are only used when the child has none
+func inherit(parent, child map[interface{}]interface{}) map[interface{}]interface{} {
+	parentApps, _ := parent["applications"].([]interface{})
+	childApps, hasApps := child["applications"].([]interface{})
+
+	merged := merge(without(parent, "applications"), without(child, "applications"))
+	if !hasApps {
+		if parentApps != nil {
+			merged["applications"] = parentApps
+		}
+		return merged
+	}
+
+	apps := []interface{}{}
+	for _, childApp := range childApps {
+		app, _ := childApp.(map[interface{}]interface{})
+		for _, parentApp := range parentApps {
+			base, _ := parentApp.(map[interface{}]interface{})
+			if base != nil && app != nil && base["name"] == app["name"] {
+				app = merge(base, app)
+				break
+			}
+		}
+		apps = append(apps, app)
+	}
+	merged["applications"] = apps
+	return merged
+}
+
+//resolve - replace the variables in node and fill each application in with
+//the manifest's top level defaults
+func resolve(node map[interface{}]interface{}, dir string, vars Vars, inherited bool) (*Manifest, error) {
+	missing := map[string]bool{}
+	used := false
+	node, _ = interpolate(node, vars, missing, &used).(map[interface{}]interface{})
+	if len(missing) > 0 {
+		names := []string{}
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("expected to find variables: %s", strings.Join(names, ", "))
+	}
+
+	defaults := without(node, "applications")
+	rawApps, _ := node["applications"].([]interface{})
+	if len(rawApps) == 0 && defaults["name"] != nil {
+		rawApps = []interface{}{map[interface{}]interface{}{}}
+	} else {
+		delete(defaults, "name")
+	}
+
+	manifest := &Manifest{expanded: inherited || used}
+	for _, rawApp := range rawApps {
+		properties, ok := rawApp.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("applications must be a list of properties")
+		}
+		properties = merge(defaults, properties)
+
+		if path, ok := properties["path"].(string); ok && path != "" && !filepath.IsAbs(path) {
+			if absolute, err := filepath.Abs(filepath.Join(dir, path)); err == nil {
+				properties["path"] = absolute
+			}
+		}
+
+		data, err := yaml.Marshal(properties)
+		if err != nil {
+			return nil, err
+		}
+		var app Application
+		if err = yaml.Unmarshal(data, &app); err != nil {
+			return nil, err
+		}
+
+		manifest.Applications = append(manifest.Applications, app)
+		manifest.raw = append(manifest.raw, properties)
+	}
+	return manifest, nil
+}
+
+//interpolate - replace every ((variable)) in node with its value, a value
+//which is just a variable keeps the type of the variable
+func interpolate(node interface{}, vars Vars, missing map[string]bool, used *bool) interface{} {
+	switch value := node.(type) {
+	case map[interface{}]interface{}:
+		result := map[interface{}]interface{}{}
+		for key, child := range value {
+			result[key] = interpolate(child, vars, missing, used)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, child := range value {
+			result[i] = interpolate(child, vars, missing, used)
+		}
+		return result
+	case string:
+		if match := variable.FindStringSubmatch(value); match != nil && match[0] == value {
+			*used = true
+			if v, ok := vars[match[1]]; ok {
+				return v
+			}
+			missing[match[1]] = true
+			return value
+		}
+
+		return variable.ReplaceAllStringFunc(value, func(ref string) string {
+			*used = true
+			name := variable.FindStringSubmatch(ref)[1]
+			if v, ok := vars[name]; ok {
+				return fmt.Sprint(v)
+			}
+			missing[name] = true
+			return ref
+		})
+	}
+	return node
+}
+
+//merge - the properties of base overridden by those of override, nested
+//properties such as env are merged too
+func merge(base, override map[interface{}]interface{}) map[interface{}]interface{} {
+	result := map[interface{}]interface{}{}
+	for key, value := range base {
+		result[key] = value
+	}
+
+	for key, value := range override {
+		baseMap, baseIsMap := result[key].(map[interface{}]interface{})
+		overrideMap, overrideIsMap := value.(map[interface{}]interface{})
+		if baseIsMap && overrideIsMap {
+			result[key] = merge(baseMap, overrideMap)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+//without - a copy of node with key left out
+func without(node map[interface{}]interface{}, key string) map[interface{}]interface{} {
+	result := map[interface{}]interface{}{}
+	for k, v := range node {
+		if k != key {
+			result[k] = v
+		}
+	}
+	return result
+}
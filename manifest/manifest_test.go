@@ -0,0 +1,373 @@
+package manifest_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/xchapter7x/autopilot/manifest"
+)
+
+var _ = Describe("Manifest", func() {
+	Describe("Parse", func() {
+		It("reads every application", func() {
+			m, err := Parse([]byte(`---
+applications:
+- name: api
+- name: worker
+`), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.AppNames()).Should(Equal([]string{"api", "worker"}))
+		})
+
+		It("reads a single app described at the top level", func() {
+			m, err := Parse([]byte("name: api\n"), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.AppNames()).Should(Equal([]string{"api"}))
+		})
+
+		It("returns an error for invalid yaml", func() {
+			_, err := Parse([]byte("applications: [\n"), nil)
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("reads the properties of each application", func() {
+			m, err := Parse([]byte(`---
+applications:
+- name: api
+  instances: 3
+  memory: 512M
+  disk_quota: 1G
+  buildpack: go_buildpack
+  routes:
+  - route: api.example.com
+  services:
+  - db
+  env:
+    DEBUG: true
+    WORKERS: 4
+  health-check-type: http
+  health-check-http-endpoint: /health
+`), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.Applications).Should(Equal([]Application{{
+				Name:                    "api",
+				Instances:               3,
+				Memory:                  "512M",
+				DiskQuota:               "1G",
+				Buildpack:               "go_buildpack",
+				Routes:                  []Route{{Route: "api.example.com"}},
+				Services:                []string{"db"},
+				Env:                     map[string]string{"DEBUG": "true", "WORKERS": "4"},
+				HealthCheckType:         "http",
+				HealthCheckHTTPEndpoint: "/health",
+			}}))
+			Ω(m.Expanded()).Should(BeFalse())
+		})
+
+		It("applies the top level properties to every application", func() {
+			m, err := Parse([]byte(`---
+memory: 256M
+env:
+  LOG_LEVEL: info
+applications:
+- name: api
+  env:
+    PORT: 8080
+- name: worker
+  memory: 1G
+`), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.Applications[0].Memory).Should(Equal("256M"))
+			Ω(m.Applications[0].Env).Should(Equal(map[string]string{"LOG_LEVEL": "info", "PORT": "8080"}))
+			Ω(m.Applications[1].Memory).Should(Equal("1G"))
+		})
+	})
+
+	Describe("variables", func() {
+		It("replaces variables with their values", func() {
+			m, err := Parse([]byte(`---
+applications:
+- name: ((name))
+  instances: ((instances))
+  routes:
+  - route: ((name)).((domain))
+`), Vars{"name": "api", "instances": 2, "domain": "example.com"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.Applications[0].Name).Should(Equal("api"))
+			Ω(m.Applications[0].Instances).Should(Equal(2))
+			Ω(m.Applications[0].Routes).Should(Equal([]Route{{Route: "api.example.com"}}))
+			Ω(m.Expanded()).Should(BeTrue())
+		})
+
+		It("gives variables from the command line the type of the scalar they spell", func() {
+			vars := Vars{"instances": ParseVar("3"), "timeout": ParseVar("180"), "no-route": ParseVar("true"), "memory": ParseVar("1G")}
+			m, err := Parse([]byte(`---
+applications:
+- name: api
+  instances: ((instances))
+  timeout: ((timeout))
+  no-route: ((no-route))
+  memory: ((memory))
+`), vars)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.Applications[0].Instances).Should(Equal(3))
+			Ω(m.Applications[0].Timeout).Should(Equal(180))
+			Ω(m.Applications[0].NoRoute).Should(BeTrue())
+			Ω(m.Applications[0].Memory).Should(Equal("1G"))
+			Ω(ParseVar("[a, b]")).Should(Equal("[a, b]"))
+		})
+
+		It("returns an error naming every variable without a value", func() {
+			_, err := Parse([]byte(`---
+applications:
+- name: ((name))
+  memory: ((memory))
+`), Vars{})
+			Ω(err).Should(MatchError("expected to find variables: memory, name"))
+		})
+	})
+
+	Describe("Load", func() {
+		var dir string
+
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			Ω(ioutil.WriteFile(path, []byte(content), 0600)).Should(Succeed())
+			return path
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "manifest")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("returns an error when the manifest does not exist", func() {
+			_, err := Load("/does/not/exist.yml", nil)
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+
+		It("merges the manifest over the one it inherits", func() {
+			write("base.yml", `---
+memory: 256M
+instances: 2
+applications:
+- name: api
+  env:
+    LOG_LEVEL: info
+    REGION: eu
+`)
+			path := write("manifest.yml", `---
+inherit: base.yml
+instances: 4
+applications:
+- name: api
+  env:
+    LOG_LEVEL: debug
+`)
+
+			m, err := Load(path, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.Applications).Should(HaveLen(1))
+			Ω(m.Applications[0].Memory).Should(Equal("256M"))
+			Ω(m.Applications[0].Instances).Should(Equal(4))
+			Ω(m.Applications[0].Env).Should(Equal(map[string]string{"LOG_LEVEL": "debug", "REGION": "eu"}))
+			Ω(m.Expanded()).Should(BeTrue())
+		})
+
+		It("uses the inherited applications when the manifest has none", func() {
+			write("base.yml", "applications:\n- name: api\n- name: worker\n")
+			path := write("manifest.yml", "inherit: base.yml\nmemory: 1G\n")
+
+			m, err := Load(path, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(m.AppNames()).Should(Equal([]string{"api", "worker"}))
+			Ω(m.Applications[1].Memory).Should(Equal("1G"))
+		})
+
+		It("returns an error when the inheritance goes round in a circle", func() {
+			write("a.yml", "inherit: b.yml\n")
+			write("b.yml", "inherit: a.yml\n")
+
+			_, err := Load(filepath.Join(dir, "a.yml"), nil)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("inherits itself"))
+		})
+
+		It("writes out a manifest cf push can use from anywhere", func() {
+			path := write("manifest.yml", `---
+memory: 256M
+applications:
+- name: ((name))
+  path: build/app.jar
+`)
+
+			m, err := Load(path, Vars{"name": "api"})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			data, err := m.Marshal()
+			Ω(err).ShouldNot(HaveOccurred())
+			expanded, err := Parse(data, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(expanded.Applications).Should(Equal([]Application{{
+				Name:   "api",
+				Memory: "256M",
+				Path:   filepath.Join(dir, "build", "app.jar"),
+			}}))
+		})
+
+		It("reads variables from a vars file", func() {
+			path := write("vars.yml", "name: api\ninstances: 3\n")
+
+			vars, err := LoadVarsFile(path)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(vars).Should(Equal(Vars{"name": "api", "instances": 3}))
+		})
+	})
+
+	Describe("Application", func() {
+		It("finds the application with the given name", func() {
+			m, _ := Parse([]byte("applications:\n- name: api\n- name: worker\n"), nil)
+			app, ok := m.Application("worker")
+			Ω(ok).Should(BeTrue())
+			Ω(app.Name).Should(Equal("worker"))
+
+			_, ok = m.Application("missing")
+			Ω(ok).Should(BeFalse())
+		})
+
+		It("treats a single application as whatever app is pushed", func() {
+			m, _ := Parse([]byte("applications:\n- name: api\n  memory: 1G\n"), nil)
+			app, ok := m.Application("other")
+			Ω(ok).Should(BeTrue())
+			Ω(app.Name).Should(Equal("other"))
+			Ω(app.Memory).Should(Equal("1G"))
+		})
+	})
+
+	Describe("Only", func() {
+		It("describes just the one application under its new name", func() {
+			m, _ := Parse([]byte("memory: 1G\napplications:\n- name: api\n  instances: 2\n- name: worker\n"), nil)
+			only := m.Only("api", "api-green")
+
+			Ω(only.AppNames()).Should(Equal([]string{"api-green"}))
+			Ω(only.Expanded()).Should(BeTrue())
+			data, err := only.Marshal()
+			Ω(err).ShouldNot(HaveOccurred())
+			reparsed, err := Parse(data, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reparsed.Applications).Should(Equal([]Application{{Name: "api-green", Instances: 2, Memory: "1G"}}))
+
+			Ω(m.AppNames()).Should(Equal([]string{"api", "worker"}))
+		})
+	})
+
+	Describe("Inherit", func() {
+		current := Application{
+			Instances: 6,
+			Memory:    "2048M",
+			DiskQuota: "1024M",
+			Env:       map[string]string{"DEBUG": "false", "REGION": "eu"},
+			Services:  []string{"db"},
+			Routes:    []Route{{Route: "api.example.com"}, {Route: "www.example.com"}},
+		}
+
+		It("fills in whatever the manifest leaves out", func() {
+			m, _ := Parse([]byte("applications:\n- name: api\n  memory: 1G\n  env:\n    DEBUG: true\n"), nil)
+			inherited := m.Inherit("api", current)
+
+			Ω(inherited).Should(Equal([]string{"instances", "disk_quota", "env", "services", "routes"}))
+			Ω(m.Expanded()).Should(BeTrue())
+			app, _ := m.Application("api")
+			Ω(app.Instances).Should(Equal(6))
+			Ω(app.Memory).Should(Equal("1G"))
+			Ω(app.Env).Should(Equal(map[string]string{"DEBUG": "true", "REGION": "eu"}))
+			Ω(app.Routes).Should(Equal(current.Routes))
+
+			data, err := m.Marshal()
+			Ω(err).ShouldNot(HaveOccurred())
+			reparsed, err := Parse(data, nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reparsed.Applications[0]).Should(Equal(app))
+		})
+
+		It("leaves routes alone when the manifest routes the app another way", func() {
+			m, _ := Parse([]byte("applications:\n- name: api\n  no-route: true\n"), nil)
+			m.Inherit("api", current)
+
+			app, _ := m.Application("api")
+			Ω(app.Routes).Should(BeEmpty())
+		})
+
+		It("changes nothing when the manifest specifies everything", func() {
+			m, _ := Parse([]byte(`---
+applications:
+- name: api
+  instances: 1
+  memory: 1G
+  disk_quota: 1G
+  env:
+    DEBUG: true
+    REGION: us
+  services: []
+  routes:
+  - route: api.example.com
+`), nil)
+			Ω(m.Inherit("api", current)).Should(BeEmpty())
+			Ω(m.Expanded()).Should(BeFalse())
+		})
+
+		It("describes the app in a new manifest", func() {
+			m := New("api")
+			m.Inherit("api", current)
+
+			app, ok := m.Application("api")
+			Ω(ok).Should(BeTrue())
+			Ω(app.Instances).Should(Equal(6))
+			Ω(app.Services).Should(Equal([]string{"db"}))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts a valid manifest", func() {
+			m, _ := Parse([]byte("applications:\n- name: api\n  memory: 1G\n  health-check-type: port\n"), nil)
+			Ω(m.Validate()).Should(Succeed())
+		})
+
+		It("reports every problem it finds", func() {
+			m, _ := Parse([]byte(`---
+applications:
+- name: api
+  memory: lots
+- name: api
+- health-check-type: tcp
+`), nil)
+			err := m.Validate()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(`application api: memory must be a size such as 512M or 1G, got "lots"`))
+			Ω(err.Error()).Should(ContainSubstring("application api is described more than once"))
+			Ω(err.Error()).Should(ContainSubstring("application #3 has no name"))
+			Ω(err.Error()).Should(ContainSubstring(`application #3: unknown health-check-type "tcp"`))
+		})
+	})
+
+	Describe("ParseMegabytes", func() {
+		It("understands megabytes and gigabytes", func() {
+			Ω(ParseMegabytes("512M")).Should(Equal(512))
+			Ω(ParseMegabytes("1G")).Should(Equal(1024))
+			Ω(ParseMegabytes("2gb")).Should(Equal(2048))
+		})
+
+		It("rejects sizes without a unit", func() {
+			_, err := ParseMegabytes("512")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
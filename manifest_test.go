@@ -0,0 +1,165 @@
+package main_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Manifest validation", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		manifestDir     string
+		pushedManifest  string
+		pushedArgs      []string
+		exitCode        int
+		restoreExit     func()
+	)
+
+	write := func(name, content string) string {
+		path := filepath.Join(manifestDir, name)
+		Ω(ioutil.WriteFile(path, []byte(content), 0600)).Should(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		var err error
+		manifestDir, err = ioutil.TempDir("", "autopilot-manifest")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		pushedManifest, pushedArgs = "", nil
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				pushedArgs = args
+				for i := range args[:len(args)-1] {
+					if args[i] == "-f" {
+						data, _ := ioutil.ReadFile(args[i+1])
+						pushedManifest = string(data)
+					}
+				}
+			}
+			return nil, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(manifestDir)
+		restoreExit()
+	})
+
+	Context("when the manifest uses variables", func() {
+		BeforeEach(func() {
+			path := write("manifest.yml", "applications:\n- name: myapp\n  instances: ((instances))\n  memory: ((memory))\n")
+			varsFile := write("vars.yml", "instances: 2\nmemory: 256M\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path, "--vars-file", varsFile, "--var", "memory=1G"})
+		})
+
+		It("then it should push an expanded copy of the manifest", func() {
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedArgs[:2]).Should(Equal([]string{"push", "myapp"}))
+			Ω(pushedArgs).ShouldNot(ContainElement("--vars-file"))
+			Ω(pushedManifest).Should(ContainSubstring("instances: 2"))
+			Ω(pushedManifest).Should(ContainSubstring("memory: 1G"))
+		})
+
+		It("then it should remove the expanded copy afterwards", func() {
+			_, err := os.Stat(pushedArgs[len(pushedArgs)-1])
+			Ω(os.IsNotExist(err)).Should(BeTrue())
+		})
+	})
+
+	Context("when --var gives a number", func() {
+		It("then it should push it as a number", func() {
+			path := write("manifest.yml", "applications:\n- name: myapp\n  instances: ((instances))\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path, "--var", "instances=3"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedManifest).Should(ContainSubstring("instances: 3"))
+		})
+	})
+
+	Context("when the manifest does not change once resolved", func() {
+		It("then it should push the manifest as it is", func() {
+			path := write("manifest.yml", "applications:\n- name: myapp\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(pushedArgs).Should(Equal([]string{"push", "myapp", "-f", path}))
+		})
+	})
+
+	Context("when a variable has no value", func() {
+		It("then it should fail pre-flight before renaming anything", func() {
+			path := write("manifest.yml", "applications:\n- name: myapp\n  memory: ((memory))\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the manifest is invalid", func() {
+		It("then it should fail pre-flight before renaming anything", func() {
+			path := write("manifest.yml", "applications:\n- name: myapp\n  memory: lots\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the app is not in the manifest", func() {
+		It("then it should fail pre-flight before renaming anything", func() {
+			path := write("manifest.yml", "applications:\n- name: api\n- name: worker\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the manifest is invalid and other pre-flight checks fail too", func() {
+		It("then it should report every problem together", func() {
+			output := &bytes.Buffer{}
+			restoreStdout := SetStdout(output)
+			defer restoreStdout()
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "myapp"},
+				plugin_models.GetAppsModel{Name: "myapp-venerable"},
+			}, nil)
+			path := write("manifest.yml", "applications:\n- name: myapp\n  memory: lots\n")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", path, "--output", "json"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(output.String()).Should(ContainSubstring("memory"))
+			Ω(output.String()).Should(ContainSubstring("myapp-venerable"))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the manifest given does not exist", func() {
+		It("then it should fail pre-flight before renaming anything", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", filepath.Join(manifestDir, "missing.yml")})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+})
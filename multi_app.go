@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//appOutcome - how the deployment of one app from a manifest went
+type appOutcome struct {
+	appName  string
+	err      error
+	started  time.Time
+	finished time.Time
+}
+
+//appsError - returned when some of the apps in a manifest were not deployed
+type appsError struct {
+	failed   int
+	total    int
+	outcomes []appOutcome
+}
+
+func (e *appsError) Error() string {
+	return fmt.Sprintf("%d of %d applications failed to deploy", e.failed, e.total)
+}
+
+//pushManifest - give every app in the manifest its own zero downtime
+//deployment, one after another, then summarise how each of them went
+func (plugin AutopilotPlugin) pushManifest(argList []string) error {
+	if plugin.manifest == nil {
+		return &preflightError{problems: []string{ErrNoManifest.Error()}}
+	}
+
+	appNames := plugin.manifest.AppNames()
+	if len(appNames) == 0 {
+		return &preflightError{problems: []string{fmt.Sprintf("manifest %s does not describe any applications", manifestPath(argList))}}
+	}
+
+	if err := plugin.preflightApps(appNames, argList); err != nil {
+		return err
+	}
+	plugin.preflighted = true
+
+	for _, appName := range appNames {
+		err := plugin.deployApp(appName, appArgs(appName, argList))
+
+		if rewindErr, ok := err.(*rewind.RewindError); ok && rewindErr.Cancelled() {
+			break
+		}
+	}
+
+	failed := plugin.printOutcomes(plugin.summary.apps, len(appNames))
+	if failed > 0 {
+		return &appsError{failed: failed, total: len(appNames), outcomes: plugin.summary.apps}
+	}
+	if plugin.options.dryRun {
+		return nil
+	}
+	return plugin.appRepo.ListApplications()
+}
+
+//preflightApps - run the pre-flight checks for every app in the manifest
+//before any of them is deployed, so a problem with a later app stops the
+//deployment before an earlier one is changed
+func (plugin AutopilotPlugin) preflightApps(appNames []string, argList []string) error {
+	problems := []string{}
+	for _, appName := range appNames {
+		plugin.setAppName(appName)
+		err := plugin.preflight(appArgs(appName, argList))
+		preflightErr, ok := err.(*preflightError)
+		if err != nil && !ok {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		for _, problem := range preflightErr.problems {
+			if problem == notLoggedIn {
+				problems = append(problems, problem)
+				return &preflightError{problems: problems}
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s", appName, problem))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &preflightError{problems: problems}
+	}
+	return nil
+}
+
+//appArgs - the cf push args for the app called appName in the manifest
+func appArgs(appName string, argList []string) []string {
+	return append([]string{"push", appName}, argList[1:]...)
+}
+
+//printOutcomes - summarise the deployment of each app, returning how many did
+//not succeed
+func (plugin AutopilotPlugin) printOutcomes(outcomes []appOutcome, total int) (failed int) {
+	succeeded := "deployed"
+	if plugin.options.dryRun {
+		succeeded = "planned"
+	}
+
+	plugin.printf("\nsummary:\n")
+	for _, outcome := range outcomes {
+		if outcome.err != nil {
+			failed++
+			plugin.printf("  %s: failed: %s\n", outcome.appName, outcome.err)
+		} else {
+			plugin.printf("  %s: %s\n", outcome.appName, succeeded)
+		}
+	}
+
+	if skipped := total - len(outcomes); skipped > 0 {
+		failed += skipped
+		plugin.printf("  %d applications were not deployed because the deployment was cancelled\n", skipped)
+	}
+	plugin.printf("\n")
+	return
+}
@@ -0,0 +1,151 @@
+package main_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Multi-app manifest", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		manifestDir     string
+		manifestPath    string
+		exitCode        int
+		restoreExit     func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		var err error
+		manifestDir, err = ioutil.TempDir("", "autopilot-manifest")
+		Ω(err).ShouldNot(HaveOccurred())
+		manifestPath = filepath.Join(manifestDir, "manifest.yml")
+		Ω(ioutil.WriteFile(manifestPath, []byte(`---
+applications:
+- name: web
+- name: worker
+`), 0600)).Should(Succeed())
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "web"},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(manifestDir)
+		restoreExit()
+	})
+
+	It("reads no app name from args without one", func() {
+		appName, args := ParseArgs([]string{"push-zdd", "-f", "manifest-path"})
+		Ω(appName).Should(BeEmpty())
+		Ω(args).Should(Equal([]string{"push", "-f", "manifest-path"}))
+	})
+
+	Context("when push-zdd is given a manifest but no app name", func() {
+		It("then it should deploy every app in the manifest in turn", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "web", "web-venerable"},
+				{"push", "web", "-f", manifestPath},
+				{"delete", "web-venerable", "-f"},
+				{"push", "worker", "-f", manifestPath},
+			}))
+		})
+
+		It("then it should accept the directory holding the manifest", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "-f", manifestDir})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(ContainElement([]string{"push", "worker", "-f", manifestDir}))
+		})
+	})
+
+	Context("when one of the apps fails to deploy", func() {
+		BeforeEach(func() {
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				if args[0] == "push" && args[1] == "web" {
+					return nil, errors.New("push failed")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "-f", manifestPath})
+		})
+
+		It("then it should roll that app back and still deploy the rest", func() {
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "web", "web-venerable"},
+				{"push", "web", "-f", manifestPath},
+				{"delete", "web", "-f"},
+				{"rename", "web-venerable", "web"},
+				{"push", "worker", "-f", manifestPath},
+			}))
+		})
+
+		It("then it should exit with the code of the app which failed", func() {
+			Ω(exitCode).Should(Equal(3))
+		})
+	})
+
+	Context("when an app which failed to deploy could not be rolled back", func() {
+		It("then it should exit with the rollback failed code", func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "web"},
+				plugin_models.GetAppsModel{Name: "worker"},
+			}, nil)
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				switch {
+				case args[0] == "push" && args[1] == "worker":
+					return nil, errors.New("push failed")
+				case args[0] == "delete" && args[1] == "worker":
+					return nil, errors.New("delete failed")
+				case args[0] == "push" && args[1] == "web":
+					return nil, errors.New("push failed")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(4))
+		})
+	})
+
+	Context("when a later app fails pre-flight", func() {
+		It("then it should fail before deploying any of the apps", func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "web"},
+				plugin_models.GetAppsModel{Name: "worker"},
+				plugin_models.GetAppsModel{Name: "worker-venerable"},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when there is no manifest", func() {
+		It("then it should fail pre-flight without touching any app", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "-f", filepath.Join(manifestDir, "missing.yml")})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"time"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//consoleObserver - reports the progress of a deployment on the console
+type consoleObserver struct {
+	plugin AutopilotPlugin
+	total  int
+}
+
+//StepStarted - announce the step and what it is about to do
+func (o consoleObserver) StepStarted(index int, action rewind.Action) {
+	o.plugin.printf("\n[%d/%d] %s: %s\n", index+1, o.total, action.Name, action.Description)
+}
+
+//StepSucceeded - report how long the step took
+func (o consoleObserver) StepSucceeded(index int, action rewind.Action, duration time.Duration) {
+	o.plugin.printf("[%d/%d] %s finished in %s\n", index+1, o.total, action.Name, round(duration))
+}
+
+//StepFailed - report why the step failed
+func (o consoleObserver) StepFailed(index int, action rewind.Action, err error, duration time.Duration) {
+	if _, ok := err.(*rewind.TimeoutError); ok {
+		o.plugin.printf("[%d/%d] %s was abandoned after %s: %s\n", index+1, o.total, action.Name, round(duration), err)
+		return
+	}
+	o.plugin.printf("[%d/%d] %s failed after %s: %s\n", index+1, o.total, action.Name, round(duration), err)
+}
+
+//StepRetrying - report a step failing for a reason which may go away
+func (o consoleObserver) StepRetrying(index int, action rewind.Action, err error, attempt int, wait time.Duration) {
+	o.plugin.printf("[%d/%d] %s failed on attempt %d of %d: %s, trying again in %s\n",
+		index+1, o.total, action.Name, attempt, o.plugin.options.maxAttempts, err, round(wait))
+}
+
+//RollbackStarted - announce the rollback
+func (o consoleObserver) RollbackStarted(err error) {
+	o.plugin.printf("\nrolling back...\n")
+}
+
+//StepReversed - report the outcome of undoing a step
+func (o consoleObserver) StepReversed(index int, action rewind.Action, err error, duration time.Duration) {
+	if err != nil {
+		o.plugin.printf("rollback of step %d (%s) failed: %s\n", index+1, action.Name, err)
+		return
+	}
+	o.plugin.printf("rollback of step %d (%s) succeeded\n", index+1, action.Name)
+}
+
+//RollbackFinished - report how the rollback went overall
+func (o consoleObserver) RollbackFinished(rewindErr *rewind.RewindError, duration time.Duration) {
+	if rewindErr.RolledBack() {
+		o.plugin.printf("rollback finished in %s\n", round(duration))
+		return
+	}
+	o.plugin.printf("rollback finished in %s with %d of %d undos failing\n", round(duration), len(rewindErr.Failed()), len(rewindErr.Reversals))
+}
+
+//round - a duration precise enough to show on the console
+func round(duration time.Duration) time.Duration {
+	return duration.Round(time.Millisecond)
+}
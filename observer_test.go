@@ -0,0 +1,66 @@
+package main_test
+
+import (
+	"bytes"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Console progress", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		output          *bytes.Buffer
+		restoreExit     func()
+		restoreStdout   func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = SetExit(func(int) {})
+		output = &bytes.Buffer{}
+		restoreStdout = SetStdout(output)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreStdout()
+		restoreExit()
+	})
+
+	It("announces each step as it runs", func() {
+		autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--no-start"})
+
+		Ω(output.String()).Should(ContainSubstring("[1/3] rename: rename myapp to myapp-venerable\n"))
+		Ω(output.String()).Should(MatchRegexp(`\[1/3\] rename finished in \d`))
+		Ω(output.String()).Should(ContainSubstring("[2/3] push: cf push myapp --no-start\n"))
+		Ω(output.String()).Should(ContainSubstring("[3/3] delete: delete myapp-venerable\n"))
+	})
+
+	It("reports the failed step and each step of the rollback", func() {
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				return nil, errors.New("push failed")
+			}
+			return nil, nil
+		}
+		autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--no-start"})
+
+		Ω(output.String()).Should(MatchRegexp(`\[2/3\] push failed after \S+: push failed`))
+		Ω(output.String()).Should(ContainSubstring("rolling back..."))
+		Ω(output.String()).Should(ContainSubstring("rollback of step 2 (push) succeeded"))
+		Ω(output.String()).Should(ContainSubstring("rollback of step 1 (rename) succeeded"))
+		Ω(output.String()).Should(ContainSubstring("rollback finished in"))
+	})
+})
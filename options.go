@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//strategies push-zdd can use to replace an app
+const (
+	manifestStrategy  = "manifest"
+	blueGreenStrategy = "blue-green"
+	canaryStrategy    = "canary"
+)
+
+//formats push-zdd can write its output in
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+//ways push-zdd can make changes to the foundation
+const (
+	clientCLI = "cli"
+	clientAPI = "api"
+)
+
+//ways push-zdd can deal with a venerable app left over from an earlier deployment
+const (
+	staleFail   = "fail"
+	staleDelete = "delete"
+	staleReuse  = "reuse"
+)
+
+//options - the flags push-zdd understands itself rather than passing to cf push
+type options struct {
+	strategy         string
+	canaryInstances  int
+	canarySteps      []int
+	canaryPause      time.Duration
+	healthTimeout    time.Duration
+	healthWindow     time.Duration
+	smokeURL         string
+	smokeExpect      int
+	smokeCount       int
+	smokeScheme      string
+	smokeCmd         string
+	bakeTime         time.Duration
+	varsFiles        []string
+	vars             map[string]string
+	onStaleVenerable string
+	dryRun           bool
+	inheritCurrent   bool
+	allowedRemovals  []string
+	output           string
+	maxAttempts      int
+	retryBackoff     time.Duration
+	stepTimeout      time.Duration
+	deployTimeout    time.Duration
+	client           string
+}
+
+//flagSpec - how a plugin flag is parsed, boolean flags take no value
+type flagSpec struct {
+	boolean bool
+	set     func(opts *options, value string) error
+}
+
+var pluginFlags = map[string]flagSpec{
+	"--strategy": {set: func(opts *options, value string) error {
+		switch value {
+		case manifestStrategy, blueGreenStrategy, canaryStrategy:
+			opts.strategy = value
+			return nil
+		}
+		return fmt.Errorf("unknown strategy %q, expected %s, %s or %s", value, manifestStrategy, blueGreenStrategy, canaryStrategy)
+	}},
+	"--canary-instances": {set: func(opts *options, value string) (err error) {
+		opts.canaryInstances, err = parsePositive("--canary-instances", value)
+		return
+	}},
+	"--steps": {set: func(opts *options, value string) (err error) {
+		opts.canarySteps, err = parseSteps(value)
+		return
+	}},
+	"--canary-pause": {set: func(opts *options, value string) (err error) {
+		opts.canaryPause, err = time.ParseDuration(value)
+		return
+	}},
+	"--health-timeout": {set: func(opts *options, value string) (err error) {
+		opts.healthTimeout, err = time.ParseDuration(value)
+		return
+	}},
+	"--health-window": {set: func(opts *options, value string) (err error) {
+		opts.healthWindow, err = time.ParseDuration(value)
+		return
+	}},
+	"--smoke-url": {set: func(opts *options, value string) error {
+		opts.smokeURL = value
+		return nil
+	}},
+	"--smoke-expect": {set: func(opts *options, value string) (err error) {
+		opts.smokeExpect, err = parsePositive("--smoke-expect", value)
+		return
+	}},
+	"--smoke-count": {set: func(opts *options, value string) (err error) {
+		opts.smokeCount, err = parsePositive("--smoke-count", value)
+		return
+	}},
+	"--bake-time": {set: func(opts *options, value string) (err error) {
+		opts.bakeTime, err = time.ParseDuration(value)
+		return
+	}},
+	"--smoke-cmd": {set: func(opts *options, value string) error {
+		opts.smokeCmd = value
+		return nil
+	}},
+	"--smoke-scheme": {set: func(opts *options, value string) error {
+		if value != "http" && value != "https" {
+			return fmt.Errorf("--smoke-scheme must be http or https, got %q", value)
+		}
+		opts.smokeScheme = value
+		return nil
+	}},
+	"--on-stale-venerable": {set: func(opts *options, value string) error {
+		switch value {
+		case staleFail, staleDelete, staleReuse:
+			opts.onStaleVenerable = value
+			return nil
+		}
+		return fmt.Errorf("unknown --on-stale-venerable %q, expected %s, %s or %s", value, staleFail, staleDelete, staleReuse)
+	}},
+	"--dry-run": {boolean: true, set: func(opts *options, value string) error {
+		opts.dryRun = true
+		return nil
+	}},
+	"--inherit-from-current": {boolean: true, set: func(opts *options, value string) error {
+		opts.inheritCurrent = true
+		return nil
+	}},
+	"--allow-service-removal": {set: func(opts *options, value string) error {
+		opts.allowedRemovals = append(opts.allowedRemovals, value)
+		return nil
+	}},
+	"--output": {set: func(opts *options, value string) error {
+		if value != outputText && value != outputJSON {
+			return fmt.Errorf("--output must be %s or %s, got %q", outputText, outputJSON, value)
+		}
+		opts.output = value
+		return nil
+	}},
+	"--max-attempts": {set: func(opts *options, value string) (err error) {
+		opts.maxAttempts, err = parsePositive("--max-attempts", value)
+		return
+	}},
+	"--retry-backoff": {set: func(opts *options, value string) (err error) {
+		opts.retryBackoff, err = time.ParseDuration(value)
+		return
+	}},
+	"--step-timeout": {set: func(opts *options, value string) (err error) {
+		opts.stepTimeout, err = time.ParseDuration(value)
+		return
+	}},
+	"--deploy-timeout": {set: func(opts *options, value string) (err error) {
+		opts.deployTimeout, err = time.ParseDuration(value)
+		return
+	}},
+	"--client": {set: func(opts *options, value string) error {
+		if value != clientCLI && value != clientAPI {
+			return fmt.Errorf("--client must be %s or %s, got %q", clientCLI, clientAPI, value)
+		}
+		opts.client = value
+		return nil
+	}},
+	"--vars-file": {set: func(opts *options, value string) error {
+		opts.varsFiles = append(opts.varsFiles, value)
+		return nil
+	}},
+	"--var": {set: func(opts *options, value string) error {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("--var must be given as name=value, got %q", value)
+		}
+		if opts.vars == nil {
+			opts.vars = map[string]string{}
+		}
+		opts.vars[parts[0]] = parts[1]
+		return nil
+	}},
+}
+
+//parseOptions - remove the plugin's own flags from args, returning them along
+//with the args which are left for cf push
+func parseOptions(args []string) (opts options, rest []string, err error) {
+	opts = options{
+		strategy:         manifestStrategy,
+		canaryInstances:  1,
+		canarySteps:      []int{25, 50, 100},
+		canaryPause:      30 * time.Second,
+		healthTimeout:    2 * time.Minute,
+		healthWindow:     10 * time.Second,
+		smokeExpect:      200,
+		smokeCount:       3,
+		smokeScheme:      "https",
+		onStaleVenerable: staleFail,
+		output:           outputText,
+		maxAttempts:      3,
+		retryBackoff:     2 * time.Second,
+		client:           clientCLI,
+	}
+
+	for i := 0; i < len(args); i++ {
+		spec, ok := pluginFlags[args[i]]
+		if !ok {
+			rest = append(rest, args[i])
+			continue
+		}
+
+		value := ""
+		if !spec.boolean {
+			if i+1 >= len(args) {
+				return opts, nil, fmt.Errorf("%s requires a value", args[i])
+			}
+			i++
+			value = args[i]
+		}
+
+		if err = spec.set(&opts, value); err != nil {
+			return opts, nil, err
+		}
+	}
+	return
+}
+
+func parsePositive(flag, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive number, got %q", flag, value)
+	}
+	return n, nil
+}
+
+//parseSteps - parse a comma separated list of increasing percentages ending at 100
+func parseSteps(value string) (steps []int, err error) {
+	for _, field := range strings.Split(value, ",") {
+		var step int
+		step, err = strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || step < 1 || step > 100 || (len(steps) > 0 && step <= steps[len(steps)-1]) {
+			return nil, fmt.Errorf("--steps must be increasing percentages between 1 and 100, got %q", value)
+		}
+		steps = append(steps, step)
+	}
+
+	if steps[len(steps)-1] != 100 {
+		return nil, fmt.Errorf("--steps must finish at 100, got %q", value)
+	}
+	return
+}
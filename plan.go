@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//stdout - where plans and progress are written
+var stdout io.Writer = os.Stdout
+
+//plan - the steps a deployment would take, shown by --dry-run
+type plan struct {
+	Type     string     `json:"type"`
+	App      string     `json:"app"`
+	Strategy string     `json:"strategy"`
+	Steps    []planStep `json:"steps"`
+}
+
+//planStep - a single step of a plan, reversible steps are undone if a later
+//one fails
+type planStep struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Reversible  bool              `json:"reversible"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+//newPlan - the plan of the deployment made up of actionList
+func (plugin AutopilotPlugin) newPlan(actionList []rewind.Action) plan {
+	p := plan{
+		Type:     planEvent,
+		App:      plugin.appName,
+		Strategy: plugin.options.strategy,
+		Steps:    []planStep{},
+	}
+
+	for _, action := range actionList {
+		p.Steps = append(p.Steps, planStep{
+			Name:        action.Name,
+			Description: action.Description,
+			Reversible:  action.Reverse != nil,
+			Metadata:    action.Metadata,
+		})
+	}
+	return p
+}
+
+//print - write the plan to w, as json when format asks for it
+func (p plan) print(w io.Writer, format string) error {
+	if format == outputJSON {
+		return json.NewEncoder(w).Encode(p)
+	}
+
+	fmt.Fprintf(w, "\nplan for %s using the %s strategy:\n", p.App, p.Strategy)
+	for i, step := range p.Steps {
+		fmt.Fprintf(w, "  %d. %s: %s\n", i+1, step.Name, step.Description)
+	}
+	fmt.Fprintln(w)
+	return nil
+}
+
+//printf - write a progress message
+func (plugin AutopilotPlugin) printf(format string, a ...interface{}) {
+	fmt.Fprintf(plugin.output(), format, a...)
+}
+
+//output - where progress messages are written, kept off stdout when it is
+//being used for json
+func (plugin AutopilotPlugin) output() io.Writer {
+	if plugin.options.output == outputJSON {
+		return os.Stderr
+	}
+	return stdout
+}
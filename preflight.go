@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xchapter7x/autopilot/manifest"
+)
+
+//preflightError - the problems which stopped a deployment before anything
+//was changed
+type preflightError struct {
+	problems []string
+}
+
+func (e *preflightError) Error() string {
+	return "pre-flight checks failed, nothing has been changed:\n  - " + strings.Join(e.problems, "\n  - ")
+}
+
+//notLoggedIn - the problem reported when there is nothing to deploy to
+const notLoggedIn = "not logged in with a space targeted, use cf login or cf target -s"
+
+//preflight - check the deployment can go ahead before anything is renamed,
+//starting from the problems loadManifest found with the manifest
+func (plugin AutopilotPlugin) preflight(argList []string) error {
+	problems := append([]string{}, plugin.manifestProblems...)
+
+	loggedIn, err := plugin.appRepo.IsLoggedIn()
+	if err != nil {
+		return err
+	}
+	if !loggedIn {
+		problems = append(problems, notLoggedIn)
+		return &preflightError{problems: problems}
+	}
+
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil {
+		return err
+	}
+
+	serviceProblems, err := plugin.checkServices()
+	if err != nil {
+		return err
+	}
+	problems = append(problems, serviceProblems...)
+
+	if problem := plugin.checkStaleVenerable(apps); problem != "" {
+		problems = append(problems, problem)
+	}
+	if problem := plugin.checkStaleGreen(apps); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	if contains(apps, plugin.appName) {
+		if plugin.options.strategy != manifestStrategy && !isStarting(argList) {
+			problems = append(problems, fmt.Sprintf("--no-start cannot be used with the %s strategy, which moves traffic to the new version, use the %s strategy instead",
+				plugin.options.strategy, manifestStrategy))
+		}
+
+		problem, err := plugin.checkMemoryQuota(argList)
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &preflightError{problems: problems}
+	}
+	return nil
+}
+
+//checkServices - every service the manifest binds the app to must exist
+func (plugin AutopilotPlugin) checkServices() (problems []string, err error) {
+	app, ok := plugin.manifestApp()
+	if !ok || len(app.Services) == 0 {
+		return nil, nil
+	}
+
+	services, err := plugin.appRepo.ListServices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, service := range app.Services {
+		if !contains(services, service) {
+			problems = append(problems, fmt.Sprintf("service %s in the manifest does not exist in this space", service))
+		}
+	}
+	return
+}
+
+//checkMemoryQuota - the org must have room for the new version to run
+//alongside the old one
+func (plugin AutopilotPlugin) checkMemoryQuota(argList []string) (string, error) {
+	limit, usage, err := plugin.appRepo.GetOrgMemory()
+	if err != nil {
+		plugin.printf("warning: could not check the org's memory quota: %s\n", err)
+		return "", nil
+	}
+	if limit <= 0 {
+		return "", nil
+	}
+
+	need, err := plugin.newVersionMemory(argList)
+	if err != nil {
+		return "", err
+	}
+
+	if usage+need > limit {
+		return fmt.Sprintf("the new version needs %dM but only %dM of the org's %dM memory quota is free", need, limit-usage, limit), nil
+	}
+	return "", nil
+}
+
+//newVersionMemory - the memory the new version will use while the old one is
+//still running, from the cf push flags, then the manifest, then the old app
+func (plugin AutopilotPlugin) newVersionMemory(argList []string) (int64, error) {
+	current, err := plugin.appRepo.GetApplication(plugin.appName)
+	if err != nil {
+		return 0, err
+	}
+
+	memory, instances := current.Memory, current.InstanceCount
+	if app, ok := plugin.manifestApp(); ok {
+		if app.Memory != "" {
+			megabytes, _ := manifest.ParseMegabytes(app.Memory)
+			memory = int64(megabytes)
+		}
+		if app.Instances > 0 {
+			instances = app.Instances
+		}
+	}
+
+	if value, ok := flagValue(argList, "-m"); ok {
+		megabytes, err := manifest.ParseMegabytes(value)
+		if err != nil {
+			return 0, fmt.Errorf("-m %s", err)
+		}
+		memory = int64(megabytes)
+	}
+	if value, ok := flagValue(argList, "-i"); ok {
+		if instances, err = strconv.Atoi(value); err != nil {
+			return 0, fmt.Errorf("-i must be a number, got %q", value)
+		}
+	}
+
+	if plugin.options.strategy == canaryStrategy {
+		instances = plugin.options.canaryInstances
+	}
+	return memory * int64(instances), nil
+}
+
+//manifestApp - the manifest's description of the app being deployed
+func (plugin AutopilotPlugin) manifestApp() (manifest.Application, bool) {
+	if plugin.manifest == nil {
+		return manifest.Application{}, false
+	}
+	return plugin.manifest.Application(plugin.appName)
+}
@@ -0,0 +1,146 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Pre-flight checks", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		manifestPath    string
+		exitCode        int
+		restoreExit     func()
+	)
+
+	withQuota := func(limit int64, usage string) {
+		org := plugin_models.Organization{}
+		org.Guid = "org-guid"
+		org.QuotaDefinition.MemoryLimit = limit
+		cliConn.GetCurrentOrgReturns(org, nil)
+		cliConn.CliCommandWithoutTerminalOutputReturns([]string{`{"memory_usage_in_mb": ` + usage + `}`}, nil)
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		manifestFile, err := ioutil.TempFile("", "manifest")
+		Ω(err).ShouldNot(HaveOccurred())
+		manifestFile.WriteString("applications:\n- name: myapp\n  memory: 256M\n  instances: 2\n  services:\n  - db\n")
+		manifestFile.Close()
+		manifestPath = manifestFile.Name()
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.GetServicesReturns([]plugin_models.GetServices_Model{
+			{Name: "db"},
+		}, nil)
+		cliConn.GetAppReturns(plugin_models.GetAppModel{
+			Name:             "myapp",
+			State:            "started",
+			Memory:           128,
+			InstanceCount:    1,
+			RunningInstances: 1,
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		os.Remove(manifestPath)
+		restoreExit()
+	})
+
+	Context("when every check passes", func() {
+		It("then it should deploy", func() {
+			withQuota(1024, "512")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"rename", "myapp", "myapp-venerable"}))
+		})
+	})
+
+	Context("when the cli is not logged in", func() {
+		It("then it should fail before renaming anything", func() {
+			cliConn.IsLoggedInReturns(false, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+			Ω(cliConn.GetAppsCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when no space is targeted", func() {
+		It("then it should fail before renaming anything", func() {
+			cliConn.HasSpaceReturns(false, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when a service in the manifest does not exist", func() {
+		It("then it should fail before renaming anything", func() {
+			cliConn.GetServicesReturns([]plugin_models.GetServices_Model{
+				{Name: "cache"},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when a venerable app is left over from an earlier deployment", func() {
+		It("then it should fail before renaming anything", func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "myapp"},
+				plugin_models.GetAppsModel{Name: "myapp-venerable"},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the org does not have the memory for both versions", func() {
+		It("then it should fail before renaming anything", func() {
+			withQuota(1024, "768")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+
+		It("then it should use the memory given to cf push over the manifest's", func() {
+			withQuota(2048, "1024")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath, "-m", "1G"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+
+	Context("when the org's memory usage cannot be read", func() {
+		It("then it should carry on without checking the quota", func() {
+			withQuota(1024, "null")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "-f", manifestPath})
+
+			Ω(exitCode).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/journal"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//ErrNoAppName - error to return when a command is missing the app to act on
+var ErrNoAppName = errors.New("an app name is required")
+
+//recoverDeployment - read the journal of the last deployment of an app and
+//either finish it or restore the venerable app
+func (plugin AutopilotPlugin) recoverDeployment(args []string) error {
+	if len(args) < 2 {
+		return ErrNoAppName
+	}
+	plugin.setAppName(args[1])
+	restore := hasFlag(args[2:], "--restore")
+
+	deployment, err := plugin.journal.Latest()
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no deployment journal found for %s at %s", plugin.appName, plugin.journal.Path())
+	}
+	if err != nil {
+		return err
+	}
+
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil {
+		return err
+	}
+
+	actionList, err := plugin.getRecoverActions(deployment, apps, restore)
+	if err != nil {
+		return err
+	}
+
+	if len(actionList) == 0 {
+		fmt.Printf("\nthere is nothing to recover for %s\n\n", plugin.appName)
+		return nil
+	}
+
+	plugin.record(recoverStep, journal.Started, journal.Started, nil)
+	actions := rewind.Actions{
+		Actions:              actionList,
+		RewindFailureMessage: "Recovery failed and could not be rolled back, you should check to see if everything is OK.",
+		Observer:             consoleObserver{plugin: plugin, total: len(actionList)},
+	}
+
+	err = actions.Execute()
+	plugin.record(recoverStep, journal.Succeeded, journal.Failed, err)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s has been recovered\n\n", plugin.appName)
+	return plugin.appRepo.ListApplications()
+}
+
+//getRecoverActions - finish the deployment if the new version had taken over,
+//otherwise put the old version back in place, in the way the strategy the
+//journal shows being used needs
+func (plugin AutopilotPlugin) getRecoverActions(deployment journal.Deployment, apps []string, restore bool) ([]rewind.Action, error) {
+	if contains(apps, plugin.venerableAppName) {
+		if _, baking := plugin.bakeDeadline(); baking {
+			fmt.Printf("\n%s is being kept to bake, use cf zdd-cleanup to remove it\n\n", plugin.venerableAppName)
+			return nil, nil
+		}
+	}
+
+	if deployment.Outcome(pushGreenStep) != "" {
+		return plugin.getBlueGreenRecoverActions(deployment, apps, restore)
+	}
+
+	if !contains(apps, plugin.venerableAppName) {
+		return nil, nil
+	}
+
+	//a stale venerable app which was reused is pushed over without a rename
+	renamed := deployment.Outcome(renameStep)
+	reused := renamed == "" && deployment.Outcome(pushStep) != ""
+	if renamed != journal.Succeeded && renamed != journal.Started && !reused {
+		return nil, fmt.Errorf("%s exists but the journal does not show it being renamed by the last deployment, leaving it alone", plugin.venerableAppName)
+	}
+
+	appFound := contains(apps, plugin.appName)
+	if appFound && tookOver(deployment) && !restore {
+		fmt.Printf("\nthe new version of %s had taken over, finishing the deployment\n\n", plugin.appName)
+		return []rewind.Action{
+			plugin.journaled(deleteStep, plugin.getDeleteAction()),
+		}, nil
+	}
+
+	fmt.Printf("\nrestoring %s from %s\n\n", plugin.appName, plugin.venerableAppName)
+	instances, _ := journaledInstances(deployment)
+	actionList := []rewind.Action{
+		plugin.journaled(reviveStep, plugin.getReviveAction(plugin.venerableAppName, instances)),
+	}
+	if appFound {
+		actionList = append(actionList, plugin.journaled(discardStep, rewind.Action{
+			Description: fmt.Sprintf("delete %s", plugin.appName),
+			Forward: func() error {
+				return plugin.appRepo.DeleteApplication(plugin.appName)
+			},
+		}))
+	}
+
+	return append(actionList, plugin.journaled(restoreStep, rewind.Action{
+		Description: fmt.Sprintf("rename %s to %s", plugin.venerableAppName, plugin.appName),
+		Retry:       noRetry,
+		Forward: func() error {
+			return plugin.appRepo.RenameApplication(plugin.venerableAppName, plugin.appName)
+		},
+	})), nil
+}
+
+//tookOver - whether the new version had taken all of the old version's work
+//when a manifest or canary deployment stopped: every instance had been moved
+//to a canary, or a pushed version had passed every check it was given
+func tookOver(deployment journal.Deployment) bool {
+	if deployment.Outcome(pushCanaryStep) != "" {
+		return deployment.Outcome(fmt.Sprintf("%s-%d", scaleStep, 100)) == journal.Succeeded
+	}
+
+	if deployment.Outcome(pushStep) != journal.Succeeded {
+		return false
+	}
+	for _, check := range []string{verifyServicesStep, verifyStep, smokeStep, smokeCmdStep} {
+		if outcome := deployment.Outcome(check); outcome != "" && outcome != journal.Succeeded {
+			return false
+		}
+	}
+	return true
+}
+
+//getBlueGreenRecoverActions - finish a blue-green deployment whose green app
+//had been promoted, otherwise give the old app back its name and routes and
+//delete the green app
+func (plugin AutopilotPlugin) getBlueGreenRecoverActions(deployment journal.Deployment, apps []string, restore bool) ([]rewind.Action, error) {
+	greenAppName := plugin.appName + "-green"
+	promoted := deployment.Outcome(promoteStep) == journal.Succeeded
+
+	if promoted && !restore {
+		if !contains(apps, plugin.venerableAppName) {
+			return nil, nil
+		}
+		fmt.Printf("\nthe new version of %s had been promoted, finishing the deployment\n\n", plugin.appName)
+		return []rewind.Action{
+			plugin.journaled(deleteStep, plugin.getDeleteAction()),
+		}, nil
+	}
+
+	//the old app is the venerable app once it has been retired
+	oldAppName, newAppName := plugin.appName, greenAppName
+	if contains(apps, plugin.venerableAppName) && (promoted || !contains(apps, plugin.appName)) {
+		oldAppName = plugin.venerableAppName
+	}
+	if promoted {
+		newAppName = plugin.appName
+	}
+	if !contains(apps, oldAppName) {
+		return nil, fmt.Errorf("neither %s nor %s exists, there is no old version to restore", plugin.appName, plugin.venerableAppName)
+	}
+	if !contains(apps, newAppName) && oldAppName == plugin.appName {
+		return nil, nil
+	}
+
+	fmt.Printf("\nrestoring %s from %s\n\n", plugin.appName, oldAppName)
+	actionList := []rewind.Action{
+		plugin.journaled(reviveStep, plugin.getReviveAction(oldAppName, 0)),
+	}
+
+	if contains(apps, newAppName) {
+		newApp, err := plugin.appRepo.GetApplication(newAppName)
+		if err != nil {
+			return nil, err
+		}
+
+		routes, tempRoutes := []plugin_models.GetApp_RouteSummary{}, []plugin_models.GetApp_RouteSummary{}
+		for _, route := range newApp.Routes {
+			if route.Host == greenAppName {
+				tempRoutes = append(tempRoutes, route)
+			} else {
+				routes = append(routes, route)
+			}
+		}
+
+		if len(routes) > 0 {
+			actionList = append(actionList, plugin.journaled(restoreRoutesStep, rewind.Action{
+				Description: fmt.Sprintf("map %s to %s", describeRoutes(routes...), oldAppName),
+				Forward: func() error {
+					return plugin.mapRoutes(oldAppName, routes...)
+				},
+			}))
+		}
+
+		actionList = append(actionList, plugin.journaled(discardStep, rewind.Action{
+			Description: fmt.Sprintf("delete %s", newAppName),
+			Forward: func() error {
+				if err := plugin.appRepo.DeleteApplication(newAppName); err != nil {
+					return err
+				}
+				for _, route := range tempRoutes {
+					if err := plugin.appRepo.DeleteRoute(route.Domain.Name, route.Host); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		}))
+	}
+
+	if oldAppName == plugin.venerableAppName {
+		actionList = append(actionList, plugin.journaled(restoreStep, rewind.Action{
+			Description: fmt.Sprintf("rename %s to %s", plugin.venerableAppName, plugin.appName),
+			Retry:       noRetry,
+			Forward: func() error {
+				return plugin.appRepo.RenameApplication(plugin.venerableAppName, plugin.appName)
+			},
+		}))
+	}
+	return actionList, nil
+}
+
+//getReviveAction - start the old version, with the instances it had before
+//the deployment when they are known, before anything else is deleted
+func (plugin AutopilotPlugin) getReviveAction(appName string, instances int) rewind.Action {
+	description := fmt.Sprintf("start %s", appName)
+	if instances > 0 {
+		description = fmt.Sprintf("scale %s to %d instances and start it", appName, instances)
+	}
+
+	return rewind.Action{
+		Description: description,
+		Forward: func() error {
+			if instances > 0 {
+				if err := plugin.appRepo.ScaleApplication(appName, instances); err != nil {
+					return err
+				}
+			}
+			return plugin.appRepo.StartApplication(appName)
+		},
+	}
+}
+
+//contains - check if s is exactly one of list
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//hasFlag - check if flag is one of args
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag {
+			return true
+		}
+	}
+	return false
+}
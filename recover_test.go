@@ -0,0 +1,280 @@
+package main_test
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+	"github.com/xchapter7x/autopilot/journal"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Deployment journal", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		appJournal      *journal.Journal
+	)
+
+	BeforeEach(func() {
+		cliConn = newCliConnection()
+		autopilotPlugin = &AutopilotPlugin{}
+		appJournal = journal.New(journal.DefaultDir(), "myapp")
+	})
+
+	AfterEach(func() {
+		os.Remove(appJournal.Path())
+	})
+
+	Context("when push-zdd replaces an existing app", func() {
+		BeforeEach(func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{
+					Name: "myapp",
+				},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp"})
+		})
+
+		It("then it should record every step of the deployment", func() {
+			deployment, err := appJournal.Latest()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(deployment.Outcome(journal.DeploymentStep)).Should(Equal(journal.Succeeded))
+			Ω(deployment.Outcome("rename")).Should(Equal(journal.Succeeded))
+			Ω(deployment.Outcome("push")).Should(Equal(journal.Succeeded))
+			Ω(deployment.Outcome("delete")).Should(Equal(journal.Succeeded))
+			Ω(deployment[0].App).Should(Equal("myapp"))
+		})
+	})
+
+	Describe("zdd-recover", func() {
+		record := func(step, outcome string) {
+			Ω(appJournal.Record(journal.Entry{App: "myapp", Step: step, Outcome: outcome})).Should(Succeed())
+		}
+
+		BeforeEach(func() {
+			record(journal.DeploymentStep, journal.Started)
+			record("rename", journal.Started)
+			record("rename", journal.Succeeded)
+			record("push", journal.Started)
+		})
+
+		Context("when the deployment stopped after renaming the app", func() {
+			BeforeEach(func() {
+				cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+					plugin_models.GetAppsModel{Name: "myapp-venerable"},
+					plugin_models.GetAppsModel{Name: "myapp"},
+				}, nil)
+				autopilotPlugin.Run(cliConn, []string{"zdd-recover", "myapp"})
+			})
+
+			It("then it should start the venerable app before discarding the half pushed app and restoring it", func() {
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(3))
+				Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"start", "myapp-venerable"}))
+				Ω(cliConn.CliCommandArgsForCall(1)).Should(Equal([]string{"delete", "myapp", "-f"}))
+				Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+			})
+
+			It("then it should record the recovery", func() {
+				deployment, err := appJournal.Latest()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(deployment.Outcome("recover")).Should(Equal(journal.Succeeded))
+				Ω(deployment.Outcome("restore")).Should(Equal(journal.Succeeded))
+			})
+		})
+
+		Context("when the deployment stopped after pushing the new version", func() {
+			BeforeEach(func() {
+				record("push", journal.Succeeded)
+				cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+					plugin_models.GetAppsModel{Name: "myapp-venerable"},
+					plugin_models.GetAppsModel{Name: "myapp"},
+				}, nil)
+			})
+
+			It("then it should finish the deployment by deleting the venerable app", func() {
+				autopilotPlugin.Run(cliConn, []string{"zdd-recover", "myapp"})
+
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(1))
+				Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"delete", "myapp-venerable", "-f"}))
+			})
+
+			It("then it should restore the venerable app when asked to", func() {
+				autopilotPlugin.Run(cliConn, []string{"zdd-recover", "myapp", "--restore"})
+
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(3))
+				Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"start", "myapp-venerable"}))
+				Ω(cliConn.CliCommandArgsForCall(1)).Should(Equal([]string{"delete", "myapp", "-f"}))
+				Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+			})
+		})
+
+		Context("when a verification of the pushed version failed", func() {
+			It("then it should restore the venerable app", func() {
+				record("push", journal.Succeeded)
+				record("smoke-test", journal.Failed)
+				cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+					plugin_models.GetAppsModel{Name: "myapp-venerable"},
+					plugin_models.GetAppsModel{Name: "myapp"},
+				}, nil)
+				autopilotPlugin.Run(cliConn, []string{"zdd-recover", "myapp"})
+
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(3))
+				Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+			})
+		})
+
+		Context("when there is no venerable app left", func() {
+			It("then it should do nothing", func() {
+				cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+					plugin_models.GetAppsModel{Name: "myapp"},
+				}, nil)
+				autopilotPlugin.Run(cliConn, []string{"zdd-recover", "myapp"})
+
+				Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+			})
+		})
+	})
+
+	Describe("zdd-recover after other strategies", func() {
+		var (
+			foundation  *repofakes.FakeFoundation
+			exitCode    int
+			restoreExit func()
+			restoreRepo func()
+		)
+
+		record := func(step, outcome string) {
+			Ω(appJournal.Record(journal.Entry{App: "myapp", Step: step, Outcome: outcome})).Should(Succeed())
+		}
+
+		recover := func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-recover", "myapp"})
+		}
+
+		BeforeEach(func() {
+			restoreExit = recordExit(&exitCode)
+			foundation = repofakes.NewFakeFoundation()
+			restoreRepo = SetApplicationRepo(foundation)
+			appJournal = journal.New(journal.TargetDir(journal.DefaultDir(), "https://api.example.com", "my-org", "my-space"), "myapp")
+			record(journal.DeploymentStep, journal.Started)
+		})
+
+		AfterEach(func() {
+			restoreRepo()
+			restoreExit()
+		})
+
+		Context("when a canary deployment stopped while deleting the venerable app", func() {
+			It("then it should keep the new version and finish the deployment", func() {
+				Ω(appJournal.Record(journal.Entry{App: "myapp", Step: "rename", Outcome: journal.Succeeded, Metadata: map[string]string{"venerable-instances": "4"}})).Should(Succeed())
+				record("push-canary", journal.Succeeded)
+				record("scale-50", journal.Succeeded)
+				record("scale-100", journal.Succeeded)
+				record("delete", journal.Started)
+				venerable := foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable"})
+				venerable.Started = false
+				current := foundation.AddApp(repofakes.FakeApp{Name: "myapp", Instances: 4}).Guid
+
+				recover()
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+				Ω(foundation.App("myapp").Guid).Should(Equal(current))
+			})
+		})
+
+		Context("when a canary deployment stopped part way through its steps", func() {
+			It("then it should give the venerable app back its instances before deleting the canary", func() {
+				Ω(appJournal.Record(journal.Entry{App: "myapp", Step: "rename", Outcome: journal.Succeeded, Metadata: map[string]string{"venerable-instances": "4"}})).Should(Succeed())
+				record("push-canary", journal.Succeeded)
+				record("scale-50", journal.Started)
+				venerable := foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable", Instances: 2})
+				venerable.Started = false
+				original := venerable.Guid
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp", Instances: 2})
+
+				recover()
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+				app := foundation.App("myapp")
+				Ω(app.Guid).Should(Equal(original))
+				Ω(app.Instances).Should(Equal(4))
+				Ω(app.Started).Should(BeTrue())
+				changes := []string{}
+				for _, call := range foundation.Calls() {
+					if strings.HasSuffix(strings.Fields(call)[0], "Application") && !strings.HasPrefix(call, "GetApplication") {
+						changes = append(changes, call)
+					}
+				}
+				Ω(changes[:3]).Should(Equal([]string{
+					"ScaleApplication myapp-venerable 4",
+					"StartApplication myapp-venerable",
+					"DeleteApplication myapp",
+				}))
+			})
+		})
+
+		Context("when a blue-green deployment stopped while mapping the routes", func() {
+			It("then it should delete the green app and its temporary route", func() {
+				record("push-green", journal.Succeeded)
+				record("map-temporary-route", journal.Succeeded)
+				record("map-routes", journal.Started)
+				original := foundation.AddApp(repofakes.FakeApp{Name: "myapp"}).Guid
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp-green", Routes: []string{"myapp-green.example.com", "myapp.example.com"}})
+
+				recover()
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+				Ω(foundation.App("myapp").Guid).Should(Equal(original))
+				Ω(foundation.App("myapp").Routes).Should(ContainElement("myapp.example.com"))
+				Ω(foundation.HasRoute("myapp-green.example.com")).Should(BeFalse())
+			})
+		})
+
+		Context("when a blue-green deployment stopped after retiring the old app", func() {
+			It("then it should give the old app back its name and routes", func() {
+				record("push-green", journal.Succeeded)
+				record("map-routes", journal.Succeeded)
+				record("unmap-routes", journal.Succeeded)
+				record("retire", journal.Succeeded)
+				record("promote", journal.Started)
+				original := foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable", Routes: []string{}}).Guid
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp-green", Routes: []string{"myapp.example.com"}})
+
+				recover()
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+				app := foundation.App("myapp")
+				Ω(app.Guid).Should(Equal(original))
+				Ω(app.Started).Should(BeTrue())
+				Ω(app.Routes).Should(ContainElement("myapp.example.com"))
+			})
+		})
+
+		Context("when a blue-green deployment stopped after promoting the green app", func() {
+			It("then it should finish the deployment by deleting the venerable app", func() {
+				record("push-green", journal.Succeeded)
+				record("retire", journal.Succeeded)
+				record("promote", journal.Succeeded)
+				foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable", Routes: []string{}})
+				current := foundation.AddApp(repofakes.FakeApp{Name: "myapp"}).Guid
+
+				recover()
+
+				Ω(exitCode).Should(Equal(0))
+				Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+				Ω(foundation.App("myapp").Guid).Should(Equal(current))
+			})
+		})
+	})
+})
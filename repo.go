@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/cloudfoundry/cli/plugin"
+	"github.com/xchapter7x/autopilot/application_repo"
+)
+
+//newRepo - creates the repo a command makes its changes with, replaced in tests
+var newRepo = newApplicationRepo
+
+//newApplicationRepo - the repo asked for by --client, running cf commands
+//without their output when stdout is being used for json
+func newApplicationRepo(conn plugin.CliConnection, opts options) application_repo.Repo {
+	cli := application_repo.NewApplicationRepo(conn)
+	if opts.output == outputJSON {
+		cli = cli.WithoutTerminalOutput()
+	}
+
+	if opts.client == clientAPI {
+		return application_repo.NewCloudControllerRepo(conn, cli)
+	}
+	return cli
+}
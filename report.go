@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//report - notes gathered while a deployment runs, printed once it has finished
+type report struct {
+	sections []reportSection
+}
+
+type reportSection struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (r *report) add(title, body string) {
+	r.sections = append(r.sections, reportSection{
+		Title: title,
+		Body:  body,
+	})
+}
+
+func (r *report) print(w io.Writer) {
+	if r == nil || len(r.sections) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\ndeployment report:\n")
+	for _, section := range r.sections {
+		fmt.Fprintf(w, "\n--- %s ---\n%s\n", section.Title, strings.TrimRight(section.Body, "\n"))
+	}
+	fmt.Fprintln(w)
+}
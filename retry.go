@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/xchapter7x/autopilot/application_repo"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//noRetry - for steps which already wait for the app themselves, so a failure
+//is not worth trying again, and for renames, which fail when tried again
+//after one which went through but reported an error
+var noRetry = &rewind.RetryPolicy{MaxAttempts: 1}
+
+//transientErrors - what the cli reports when the rpc connection to it or the
+//cloud controller behind it has a problem which may go away by itself
+var transientErrors = []string{
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"i/o timeout",
+	"EOF",
+	"TLS handshake timeout",
+	"status code: 502",
+	"status code: 503",
+	"status code: 504",
+	"Server error",
+}
+
+//retryPolicy - how steps are retried when they fail for a transient reason
+func (plugin AutopilotPlugin) retryPolicy() *rewind.RetryPolicy {
+	return &rewind.RetryPolicy{
+		MaxAttempts: plugin.options.maxAttempts,
+		Backoff:     plugin.options.retryBackoff,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0.2,
+		Retryable:   isTransient,
+	}
+}
+
+//isTransient - whether err looks like a temporary problem talking to the cli
+//or the cloud controller
+func isTransient(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	if apiErr, ok := err.(*application_repo.APIError); ok {
+		return apiErr.Temporary()
+	}
+
+	for _, transient := range transientErrors {
+		if strings.Contains(err.Error(), transient) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package main_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	"github.com/xchapter7x/autopilot/application_repo"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Retrying steps", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		exitCode        int
+		restoreExit     func()
+	)
+
+	//failDeletes - make the first n deletes of the venerable app fail with err
+	failDeletes := func(n int, err error) {
+		failures := 0
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "delete" && args[1] == "myapp-venerable" && failures < n {
+				failures++
+				return nil, err
+			}
+			return nil, nil
+		}
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+	})
+
+	Context("when a step fails for a transient reason", func() {
+		It("then it should try the step again and carry on", func() {
+			failDeletes(2, errors.New("dial tcp 127.0.0.1:43210: connect: connection refused"))
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--no-start", "--retry-backoff", "1ms"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp", "--no-start"},
+				{"delete", "myapp-venerable", "-f"},
+				{"delete", "myapp-venerable", "-f"},
+				{"delete", "myapp-venerable", "-f"},
+			}))
+		})
+
+		It("then it should try again when the cloud controller is unavailable", func() {
+			failDeletes(1, &application_repo.APIError{Method: "DELETE", Path: "/v2/apps/guid", StatusCode: 503})
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--retry-backoff", "1ms"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)[2:]).Should(Equal([][]string{
+				{"delete", "myapp-venerable", "-f"},
+				{"delete", "myapp-venerable", "-f"},
+			}))
+		})
+
+		It("then it should give up after --max-attempts", func() {
+			failDeletes(5, errors.New("Server error, status code: 502"))
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--max-attempts", "2", "--retry-backoff", "1ms"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"delete", "myapp-venerable", "-f"},
+				{"delete", "myapp-venerable", "-f"},
+				{"delete", "myapp", "-f"},
+				{"rename", "myapp-venerable", "myapp"},
+			}))
+		})
+
+		It("then it should not try a rename again, as it may have gone through", func() {
+			failures := 0
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				if args[0] == "rename" && failures == 0 {
+					failures++
+					return nil, errors.New("EOF")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--retry-backoff", "1ms"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+			}))
+		})
+	})
+
+	Context("when a step fails for any other reason", func() {
+		It("then it should not try the step again", func() {
+			failDeletes(1, errors.New("app myapp-venerable is in use"))
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--retry-backoff", "1ms"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"delete", "myapp-venerable", "-f"},
+				{"delete", "myapp", "-f"},
+				{"rename", "myapp-venerable", "myapp"},
+			}))
+		})
+	})
+})
@@ -1,38 +1,440 @@
 package rewind
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
 
+//Actions - an ordered list of actions which are unwound if any of them fail
 type Actions struct {
 	Actions []Action
 
 	RewindFailureMessage string
+
+	//Observer - told about each step as it runs and is undone, may be nil
+	Observer Observer
+
+	//Timeout - the longest all of the actions may take to run forward, zero
+	//for no limit. The rollback is not limited
+	Timeout time.Duration
+
+	//Grace - how long the Forward of a step which ran out of time or was
+	//cancelled is given to return before the rollback starts, so the undo
+	//does not race it. A step which returns nil in that time is undone by its
+	//Reverse as a completed one. Zero to start the rollback straight away,
+	//negative to wait for Forward however long it takes
+	Grace time.Duration
 }
 
+//Execute - run each action in order. If one fails, its ReversePrevious is
+//run and then every action which already completed is reversed, most recent
+//first
 func (actions Actions) Execute() error {
-	for _, action := range actions.Actions {
-		err := action.Forward()
-		if err != nil {
-			if action.ReversePrevious == nil {
-				return err
-			}
+	return actions.ExecuteContext(context.Background())
+}
 
-			reverseError := action.ReversePrevious()
-			if reverseError != nil {
-				if actions.RewindFailureMessage != "" {
-					return fmt.Errorf("%s: %s", actions.RewindFailureMessage, reverseError)
-				} else {
-					return reverseError
-				}
+//ExecuteContext - run the actions as Execute does, but stop as soon as ctx is
+//done. The action in flight is given Grace to return. If it completes in that
+//time it is reversed along with the others, otherwise it is abandoned and
+//treated as failed, so its ReversePrevious runs before every completed action
+//is reversed. When a step runs out of time, or ctx passes its deadline, it
+//fails with a *TimeoutError
+func (actions Actions) ExecuteContext(ctx context.Context) error {
+	if actions.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, actions.Timeout)
+		defer cancel()
+	}
+
+	observer := actions.observer()
+	for i, action := range actions.Actions {
+		if ctx.Err() != nil {
+			return actions.rewind(i, -1, actions.stopped(ctx, i, action))
+		}
+
+		observer.StepStarted(i, action)
+		started := time.Now()
+		stepCtx, cancel := actions.stepContext(ctx, action)
+		done := make(chan error, 1)
+		go func(index int, action Action) {
+			done <- actions.forward(stepCtx, index, action)
+		}(i, action)
+
+		var err error
+		select {
+		case err = <-done:
+			if err != nil && stepCtx.Err() != nil {
+				err = actions.stopped(ctx, i, action)
 			}
+		case <-stepCtx.Done():
+			err = actions.stopped(ctx, i, action)
+			if actions.settle(done, action) {
+				cancel()
+				observer.StepSucceeded(i, action, time.Since(started))
+				return actions.rewind(i+1, -1, err)
+			}
+		}
+		cancel()
 
-			return err
+		if err != nil {
+			observer.StepFailed(i, action, err, time.Since(started))
+			return actions.rewind(i, i, err)
 		}
+		observer.StepSucceeded(i, action, time.Since(started))
 	}
 
 	return nil
 }
 
+//settle - wait up to Grace for the Forward of a stopped action to return,
+//telling the action it has been abandoned if it does not. Returns whether
+//the action completed after all
+func (actions Actions) settle(done <-chan error, action Action) bool {
+	if actions.Grace < 0 {
+		return <-done == nil
+	}
+	if actions.Grace > 0 {
+		select {
+		case err := <-done:
+			return err == nil
+		case <-time.After(actions.Grace):
+		}
+	}
+
+	if action.Abandoned != nil {
+		action.Abandoned()
+	}
+	return false
+}
+
+//stepContext - the context action runs forward in, limited by its Timeout
+func (actions Actions) stepContext(ctx context.Context, action Action) (context.Context, context.CancelFunc) {
+	if action.Timeout > 0 {
+		return context.WithTimeout(ctx, action.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+//stopped - why the action at index was abandoned or never started, a
+//*TimeoutError unless ctx was cancelled
+func (actions Actions) stopped(ctx context.Context, index int, action Action) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return context.Canceled
+	case context.DeadlineExceeded:
+		return &TimeoutError{Index: index, Step: action.Name, Timeout: actions.Timeout, Overall: true}
+	}
+	return &TimeoutError{Index: index, Step: action.Name, Timeout: action.Timeout}
+}
+
+//forward - run the Forward of action, trying again as its retry policy allows
+func (actions Actions) forward(ctx context.Context, index int, action Action) error {
+	for attempt := 1; ; attempt++ {
+		err := action.Forward()
+		if err == nil || !action.Retry.allows(attempt, err) {
+			return err
+		}
+
+		wait := action.Retry.Wait(attempt)
+		if ctx.Err() != nil {
+			return err
+		}
+		if observer, ok := actions.observer().(RetryObserver); ok {
+			observer.StepRetrying(index, action, err, attempt, wait)
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+//rewind - undo the actions before stopped. cleanup is the index of the action
+//whose ReversePrevious should run first, or -1 when none had started
+func (actions Actions) rewind(stopped, cleanup int, err error) error {
+	rewindErr := &RewindError{
+		Err:     err,
+		Message: actions.RewindFailureMessage,
+	}
+
+	observer := actions.observer()
+	observer.RollbackStarted(err)
+	started := time.Now()
+
+	if cleanup >= 0 && actions.Actions[cleanup].ReversePrevious != nil {
+		rewindErr.Reversals = append(rewindErr.Reversals, actions.reverse(cleanup, actions.Actions[cleanup].ReversePrevious))
+	}
+
+	for i := stopped - 1; i >= 0; i-- {
+		if reverse := actions.Actions[i].Reverse; reverse != nil {
+			rewindErr.Reversals = append(rewindErr.Reversals, actions.reverse(i, reverse))
+		}
+	}
+
+	observer.RollbackFinished(rewindErr, time.Since(started))
+	return rewindErr
+}
+
+//reverse - run the undo of the action at index, telling the observer how it went
+func (actions Actions) reverse(index int, undo func() error) Reversal {
+	started := time.Now()
+	reversal := Reversal{
+		Index: index,
+		Err:   undo(),
+	}
+	actions.observer().StepReversed(index, actions.Actions[index], reversal.Err, time.Since(started))
+	return reversal
+}
+
+func (actions Actions) observer() Observer {
+	if actions.Observer == nil {
+		return NopObserver{}
+	}
+	return actions.Observer
+}
+
+//Observer - receives the progress of actions as they are executed and rewound.
+//index is the position of the action in Actions
+type Observer interface {
+	StepStarted(index int, action Action)
+	StepSucceeded(index int, action Action, duration time.Duration)
+	//StepFailed - the action failed, or was abandoned when its context was done
+	StepFailed(index int, action Action, err error, duration time.Duration)
+	//RollbackStarted - err stopped the actions and the completed ones are being undone
+	RollbackStarted(err error)
+	//StepReversed - the Reverse or ReversePrevious of an action has been run
+	StepReversed(index int, action Action, err error, duration time.Duration)
+	RollbackFinished(rewindErr *RewindError, duration time.Duration)
+}
+
+//RetryObserver - an Observer which also wants to know when a failed step is
+//about to be tried again
+type RetryObserver interface {
+	Observer
+	StepRetrying(index int, action Action, err error, attempt int, wait time.Duration)
+}
+
+//NopObserver - an Observer which ignores everything, embed it to only
+//handle some events
+type NopObserver struct{}
+
+//StepStarted - ignored
+func (NopObserver) StepStarted(int, Action) {}
+
+//StepSucceeded - ignored
+func (NopObserver) StepSucceeded(int, Action, time.Duration) {}
+
+//StepFailed - ignored
+func (NopObserver) StepFailed(int, Action, error, time.Duration) {}
+
+//RollbackStarted - ignored
+func (NopObserver) RollbackStarted(error) {}
+
+//StepReversed - ignored
+func (NopObserver) StepReversed(int, Action, error, time.Duration) {}
+
+//RollbackFinished - ignored
+func (NopObserver) RollbackFinished(*RewindError, time.Duration) {}
+
+//Action - a single step with an optional way of undoing it
 type Action struct {
-	Forward         func() error
+	//Name - a short name for the step, used in logs and plans
+	Name string
+	//Description - what the step will do, in words
+	Description string
+	//Metadata - anything else worth knowing about the step, such as the app it acts on
+	Metadata map[string]string
+	//Forward - performs the step
+	Forward func() error
+	//Reverse - undoes the step once it has completed, used when a later action fails
+	Reverse func() error
+	//ReversePrevious - cleans up after this step when its own Forward fails
 	ReversePrevious func() error
+	//Retry - how a failing Forward is tried again before the step fails, nil
+	//for no retries
+	Retry *RetryPolicy
+	//Timeout - the longest Forward may take, retries included, before the step
+	//fails, zero for no limit
+	Timeout time.Duration
+	//Abandoned - called when Forward is still running after the step was
+	//stopped and the grace period is over. Forward may carry on, but whatever
+	//it reports afterwards is ignored, may be nil
+	Abandoned func()
+}
+
+//RetryPolicy - how a step which fails is tried again
+type RetryPolicy struct {
+	//MaxAttempts - the most times Forward is run, including the first
+	MaxAttempts int
+	//Backoff - the wait before the first retry, doubling for each one after
+	Backoff time.Duration
+	//MaxBackoff - the longest wait between attempts, zero for no limit
+	MaxBackoff time.Duration
+	//Jitter - up to this fraction of each wait is taken off at random, so
+	//many clients do not retry in step
+	Jitter float64
+	//Retryable - whether err is worth trying again, every error is when nil
+	Retryable func(err error) bool
+}
+
+//Wait - how long to wait after the given failed attempt before the next
+func (policy *RetryPolicy) Wait(attempt int) time.Duration {
+	wait := policy.Backoff
+	for i := 1; i < attempt && (policy.MaxBackoff == 0 || wait < policy.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+		wait = policy.MaxBackoff
+	}
+	return wait - time.Duration(float64(wait)*policy.Jitter*rand.Float64())
+}
+
+//allows - whether another attempt should follow the given failed attempt
+func (policy *RetryPolicy) allows(attempt int, err error) bool {
+	if policy == nil || attempt >= policy.MaxAttempts {
+		return false
+	}
+	return policy.Retryable == nil || policy.Retryable(err)
+}
+
+//TimeoutError - a step ran out of time and was abandoned. Its Forward may
+//still be running, nothing can interrupt it
+type TimeoutError struct {
+	Index int
+	Step  string
+	//Timeout - the time which ran out, zero when it was the deadline of the
+	//context given to ExecuteContext
+	Timeout time.Duration
+	//Overall - true when all of the actions ran out of time rather than the step
+	Overall bool
+}
+
+func (e *TimeoutError) Error() string {
+	step := e.Step
+	if step == "" {
+		step = fmt.Sprintf("#%d", e.Index+1)
+	}
+
+	switch {
+	case !e.Overall:
+		return fmt.Sprintf("step %s timed out after %s", step, e.Timeout)
+	case e.Timeout > 0:
+		return fmt.Sprintf("timed out after %s during step %s", e.Timeout, step)
+	}
+	return fmt.Sprintf("deadline passed during step %s", step)
+}
+
+//Kind - how actions which did not complete ended up
+type Kind int
+
+//kinds of RewindError, from the most to the least serious
+const (
+	//RollbackFailed - one or more undos failed, the actions are half done
+	RollbackFailed Kind = iota + 1
+	//Cancelled - the context was cancelled and the actions were rolled back
+	Cancelled
+	//TimedOut - a step or all of them ran out of time and were rolled back
+	TimedOut
+	//RolledBack - a step failed and the actions were rolled back
+	RolledBack
+)
+
+func (k Kind) String() string {
+	switch k {
+	case RollbackFailed:
+		return "rollback failed"
+	case Cancelled:
+		return "cancelled"
+	case TimedOut:
+		return "timed out"
+	case RolledBack:
+		return "rolled back"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+//Reversal - the outcome of undoing a single action
+type Reversal struct {
+	Index int
+	Err   error
+}
+
+//RewindError - returned when an action fails, records every undo attempted
+type RewindError struct {
+	Err       error
+	Message   string
+	Reversals []Reversal
+}
+
+//Failed - the reversals which did not succeed
+func (e *RewindError) Failed() (failed []Reversal) {
+	for _, reversal := range e.Reversals {
+		if reversal.Err != nil {
+			failed = append(failed, reversal)
+		}
+	}
+	return
+}
+
+//Succeeded - the reversals which undid their action
+func (e *RewindError) Succeeded() (succeeded []Reversal) {
+	for _, reversal := range e.Reversals {
+		if reversal.Err == nil {
+			succeeded = append(succeeded, reversal)
+		}
+	}
+	return
+}
+
+//Kind - how the actions ended up, a failed undo outweighs why they stopped
+func (e *RewindError) Kind() Kind {
+	switch {
+	case !e.RolledBack():
+		return RollbackFailed
+	case e.Cancelled():
+		return Cancelled
+	case e.TimedOut():
+		return TimedOut
+	}
+	return RolledBack
+}
+
+//Cancelled - true when the actions stopped because their context was done
+func (e *RewindError) Cancelled() bool {
+	return e.Err == context.Canceled
+}
+
+//TimedOut - true when the actions stopped because a step or all of them ran
+//out of time
+func (e *RewindError) TimedOut() bool {
+	_, ok := e.Err.(*TimeoutError)
+	return ok
+}
+
+//RolledBack - true when every undo that was attempted succeeded
+func (e *RewindError) RolledBack() bool {
+	return len(e.Failed()) == 0
+}
+
+func (e *RewindError) Error() string {
+	failed := e.Failed()
+	if len(failed) == 0 {
+		return e.Err.Error()
+	}
+
+	messages := make([]string, len(failed))
+	for i, reversal := range failed {
+		messages[i] = reversal.Err.Error()
+	}
+	reverseError := strings.Join(messages, "; ")
+
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %s", e.Message, reverseError)
+	}
+	return reverseError
 }
@@ -1,7 +1,11 @@
 package rewind_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -164,4 +168,661 @@ var _ = Describe("Rewind", func() {
 		Ω(secondReverseRun).Should(BeTrue())
 		Ω(thirdRun).Should(BeFalse())
 	})
+
+	Describe("compensating rollback", func() {
+		var (
+			calls   []string
+			actions rewind.Actions
+		)
+
+		step := func(name string, forwardErr, reverseErr error) rewind.Action {
+			return rewind.Action{
+				Forward: func() error {
+					calls = append(calls, name)
+					return forwardErr
+				},
+				Reverse: func() error {
+					calls = append(calls, "undo "+name)
+					return reverseErr
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			calls = []string{}
+		})
+
+		It("reverses every completed action in reverse order when a later one fails", func() {
+			actions = rewind.Actions{
+				Actions: []rewind.Action{
+					step("rename", nil, nil),
+					step("push", nil, nil),
+					step("delete", errors.New("disaster"), nil),
+				},
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("disaster"))
+			Ω(calls).Should(Equal([]string{"rename", "push", "delete", "undo push", "undo rename"}))
+		})
+
+		It("does not reverse the failed action itself", func() {
+			actions = rewind.Actions{
+				Actions: []rewind.Action{
+					step("rename", errors.New("disaster"), nil),
+				},
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("disaster"))
+			Ω(calls).Should(Equal([]string{"rename"}))
+		})
+
+		It("runs the failed action's ReversePrevious before unwinding the rest", func() {
+			failing := step("push", errors.New("disaster"), nil)
+			failing.ReversePrevious = func() error {
+				calls = append(calls, "cleanup push")
+				return nil
+			}
+			actions = rewind.Actions{
+				Actions: []rewind.Action{
+					step("rename", nil, nil),
+					failing,
+				},
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("disaster"))
+			Ω(calls).Should(Equal([]string{"rename", "push", "cleanup push", "undo rename"}))
+		})
+
+		It("keeps unwinding after an undo fails and reports which undos succeeded", func() {
+			actions = rewind.Actions{
+				Actions: []rewind.Action{
+					step("rename", nil, nil),
+					step("push", nil, errors.New("cannot delete")),
+					step("delete", errors.New("disaster"), nil),
+				},
+				RewindFailureMessage: "uh oh",
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("uh oh: cannot delete"))
+			Ω(calls).Should(Equal([]string{"rename", "push", "delete", "undo push", "undo rename"}))
+
+			rewindErr, ok := err.(*rewind.RewindError)
+			Ω(ok).Should(BeTrue())
+			Ω(rewindErr.Err).Should(MatchError("disaster"))
+			Ω(rewindErr.RolledBack()).Should(BeFalse())
+			Ω(rewindErr.Failed()).Should(HaveLen(1))
+			Ω(rewindErr.Failed()[0].Index).Should(Equal(1))
+			Ω(rewindErr.Succeeded()).Should(HaveLen(1))
+			Ω(rewindErr.Succeeded()[0].Index).Should(Equal(0))
+		})
+	})
+
+	Describe("Kind", func() {
+		It("classifies why the actions stopped", func() {
+			Ω((&rewind.RewindError{Err: errors.New("push failed")}).Kind()).Should(Equal(rewind.RolledBack))
+			Ω((&rewind.RewindError{Err: context.Canceled}).Kind()).Should(Equal(rewind.Cancelled))
+			Ω((&rewind.RewindError{Err: &rewind.TimeoutError{Step: "push"}}).Kind()).Should(Equal(rewind.TimedOut))
+		})
+
+		It("puts a failed undo before why the actions stopped", func() {
+			rewindErr := &rewind.RewindError{
+				Err:       context.Canceled,
+				Reversals: []rewind.Reversal{{Index: 0, Err: errors.New("rename failed")}},
+			}
+			Ω(rewindErr.Kind()).Should(Equal(rewind.RollbackFailed))
+			Ω(rewindErr.Kind().String()).Should(Equal("rollback failed"))
+		})
+	})
+
+	Describe("ExecuteContext", func() {
+		It("abandons the action in flight, cleans up after it and reverses the completed ones when cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			release := make(chan struct{})
+			defer close(release)
+
+			calls := []string{}
+			actions := rewind.Actions{
+				Actions: []rewind.Action{
+					{
+						Forward: func() error {
+							calls = append(calls, "rename")
+							return nil
+						},
+						Reverse: func() error {
+							calls = append(calls, "undo rename")
+							return nil
+						},
+					},
+					{
+						Forward: func() error {
+							cancel()
+							<-release
+							return nil
+						},
+						ReversePrevious: func() error {
+							calls = append(calls, "cleanup push")
+							return nil
+						},
+					},
+				},
+			}
+
+			err := actions.ExecuteContext(ctx)
+			Ω(err).Should(MatchError("context canceled"))
+			Ω(err.(*rewind.RewindError).Cancelled()).Should(BeTrue())
+			Ω(calls).Should(Equal([]string{"rename", "cleanup push", "undo rename"}))
+		})
+
+		It("does not start when the context is already done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			run := false
+			actions := rewind.Actions{
+				Actions: []rewind.Action{
+					{
+						Forward: func() error {
+							run = true
+							return nil
+						},
+					},
+				},
+			}
+
+			err := actions.ExecuteContext(ctx)
+			Ω(err).Should(MatchError("context canceled"))
+			Ω(run).Should(BeFalse())
+		})
+	})
+
+	Describe("Timeouts", func() {
+		var (
+			hang    func() error
+			release func()
+		)
+
+		BeforeEach(func() {
+			released := make(chan struct{})
+			hang = func() error {
+				<-released
+				return nil
+			}
+			release = func() { close(released) }
+		})
+
+		AfterEach(func() {
+			release()
+		})
+
+		It("fails a step which takes longer than its timeout and rolls back", func() {
+			calls := []string{}
+			actions := rewind.Actions{
+				Actions: []rewind.Action{
+					{
+						Name: "rename",
+						Forward: func() error {
+							calls = append(calls, "rename")
+							return nil
+						},
+						Reverse: func() error {
+							calls = append(calls, "undo rename")
+							return nil
+						},
+					},
+					{
+						Name:    "push",
+						Timeout: 10 * time.Millisecond,
+						Forward: hang,
+						ReversePrevious: func() error {
+							calls = append(calls, "cleanup push")
+							return nil
+						},
+					},
+				},
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("step push timed out after 10ms"))
+			Ω(err.(*rewind.RewindError).TimedOut()).Should(BeTrue())
+			Ω(err.(*rewind.RewindError).Cancelled()).Should(BeFalse())
+			Ω(calls).Should(Equal([]string{"rename", "cleanup push", "undo rename"}))
+		})
+
+		It("waits the grace period for a step which times out before undoing it", func() {
+			calls := make(chan string, 3)
+			actions := rewind.Actions{
+				Grace: time.Second,
+				Actions: []rewind.Action{{
+					Name:    "push",
+					Timeout: 10 * time.Millisecond,
+					Forward: func() error {
+						time.Sleep(50 * time.Millisecond)
+						calls <- "push returned"
+						return nil
+					},
+					Reverse: func() error {
+						calls <- "undo push"
+						return nil
+					},
+					ReversePrevious: func() error {
+						calls <- "cleanup push"
+						return nil
+					},
+					Abandoned: func() {
+						calls <- "abandoned"
+					},
+				}},
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("step push timed out after 10ms"))
+			Ω(err.(*rewind.RewindError).Reversals).Should(Equal([]rewind.Reversal{{Index: 0}}))
+			close(calls)
+			Ω(calls).Should(Receive(Equal("push returned")))
+			Ω(calls).Should(Receive(Equal("undo push")))
+			Ω(calls).ShouldNot(Receive())
+		})
+
+		It("cleans up after a step which fails in the grace period", func() {
+			calls := make(chan string, 3)
+			actions := rewind.Actions{
+				Grace: time.Second,
+				Actions: []rewind.Action{{
+					Name:    "push",
+					Timeout: 10 * time.Millisecond,
+					Forward: func() error {
+						time.Sleep(50 * time.Millisecond)
+						calls <- "push returned"
+						return errors.New("push failed")
+					},
+					Reverse: func() error {
+						calls <- "undo push"
+						return nil
+					},
+					ReversePrevious: func() error {
+						calls <- "cleanup push"
+						return nil
+					},
+					Abandoned: func() {
+						calls <- "abandoned"
+					},
+				}},
+			}
+
+			Ω(actions.Execute()).Should(MatchError("step push timed out after 10ms"))
+			close(calls)
+			Ω(calls).Should(Receive(Equal("push returned")))
+			Ω(calls).Should(Receive(Equal("cleanup push")))
+			Ω(calls).ShouldNot(Receive())
+		})
+
+		It("abandons a step which is still running after the grace period", func() {
+			abandoned := false
+			actions := rewind.Actions{
+				Grace: 10 * time.Millisecond,
+				Actions: []rewind.Action{{
+					Name:    "push",
+					Timeout: 10 * time.Millisecond,
+					Forward: hang,
+					Abandoned: func() {
+						abandoned = true
+					},
+				}},
+			}
+
+			Ω(actions.Execute()).Should(MatchError("step push timed out after 10ms"))
+			Ω(abandoned).Should(BeTrue())
+		})
+
+		It("waits for a stopped step however long it takes when the grace period is negative", func() {
+			calls := []string{}
+			actions := rewind.Actions{
+				Grace: -1,
+				Actions: []rewind.Action{{
+					Name:    "push",
+					Timeout: 10 * time.Millisecond,
+					Forward: func() error {
+						time.Sleep(100 * time.Millisecond)
+						calls = append(calls, "push returned")
+						return nil
+					},
+					Reverse: func() error {
+						calls = append(calls, "undo push")
+						return nil
+					},
+					Abandoned: func() {
+						calls = append(calls, "abandoned")
+					},
+				}},
+			}
+
+			Ω(actions.Execute()).Should(MatchError("step push timed out after 10ms"))
+			Ω(calls).Should(Equal([]string{"push returned", "undo push"}))
+		})
+
+		It("leaves a step alone which finishes in time", func() {
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Timeout: time.Minute,
+					Forward: func() error { return nil },
+				}},
+			}
+
+			Ω(actions.Execute()).Should(Succeed())
+		})
+
+		It("stops retrying a step once its time is up", func() {
+			var attempts int32
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Timeout: 10 * time.Millisecond,
+					Forward: func() error {
+						atomic.AddInt32(&attempts, 1)
+						return errors.New("connection refused")
+					},
+					Retry: &rewind.RetryPolicy{MaxAttempts: 3, Backoff: time.Hour},
+				}},
+			}
+
+			err := actions.Execute()
+			Ω(err.(*rewind.RewindError).TimedOut()).Should(BeTrue())
+			Ω(atomic.LoadInt32(&attempts)).Should(Equal(int32(1)))
+		})
+
+		It("fails the step in flight when all of the actions run out of time", func() {
+			undone := false
+			actions := rewind.Actions{
+				Actions: []rewind.Action{
+					{
+						Name:    "rename",
+						Forward: func() error { return nil },
+						Reverse: func() error {
+							undone = true
+							return nil
+						},
+					},
+					{Name: "push", Forward: hang},
+				},
+				Timeout: 10 * time.Millisecond,
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(MatchError("timed out after 10ms during step push"))
+			Ω(err.(*rewind.RewindError).TimedOut()).Should(BeTrue())
+			Ω(undone).Should(BeTrue())
+		})
+
+		It("treats the deadline of the context as a timeout", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{Name: "push", Forward: hang}},
+			}
+
+			err := actions.ExecuteContext(ctx)
+			Ω(err).Should(MatchError("deadline passed during step push"))
+			Ω(err.(*rewind.RewindError).TimedOut()).Should(BeTrue())
+		})
+	})
+
+	Describe("Observer", func() {
+		var observer *recordingObserver
+
+		step := func(name string, forwardErr error) rewind.Action {
+			return rewind.Action{
+				Name:    name,
+				Forward: func() error { return forwardErr },
+				Reverse: func() error { return nil },
+			}
+		}
+
+		BeforeEach(func() {
+			observer = &recordingObserver{}
+		})
+
+		It("is told about each step as it succeeds", func() {
+			actions := rewind.Actions{
+				Actions:  []rewind.Action{step("rename", nil), step("push", nil)},
+				Observer: observer,
+			}
+
+			Ω(actions.Execute()).Should(Succeed())
+			Ω(observer.events).Should(Equal([]string{
+				"started 0 rename",
+				"succeeded 0 rename",
+				"started 1 push",
+				"succeeded 1 push",
+			}))
+		})
+
+		It("is told about the failure and every step of the rollback", func() {
+			actions := rewind.Actions{
+				Actions:  []rewind.Action{step("rename", nil), step("push", errors.New("disaster"))},
+				Observer: observer,
+			}
+
+			err := actions.Execute()
+			Ω(err).Should(HaveOccurred())
+			Ω(observer.events).Should(Equal([]string{
+				"started 0 rename",
+				"succeeded 0 rename",
+				"started 1 push",
+				"failed 1 push: disaster",
+				"rollback started: disaster",
+				"reversed 0 rename",
+				"rollback finished: 1 reversals",
+			}))
+			Ω(observer.rewindErr).Should(Equal(err))
+		})
+
+		It("is told about an abandoned step when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Name: "push",
+					Forward: func() error {
+						cancel()
+						time.Sleep(10 * time.Millisecond)
+						return nil
+					},
+				}},
+				Observer: observer,
+			}
+
+			Ω(actions.ExecuteContext(ctx)).Should(HaveOccurred())
+			Ω(observer.events).Should(Equal([]string{
+				"started 0 push",
+				"failed 0 push: context canceled",
+				"rollback started: context canceled",
+				"rollback finished: 0 reversals",
+			}))
+		})
+
+		It("times each step", func() {
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Name: "push",
+					Forward: func() error {
+						time.Sleep(20 * time.Millisecond)
+						return nil
+					},
+				}},
+				Observer: observer,
+			}
+
+			Ω(actions.Execute()).Should(Succeed())
+			Ω(observer.durations).Should(HaveLen(1))
+			Ω(observer.durations[0]).Should(BeNumerically(">=", 20*time.Millisecond))
+		})
+	})
+
+	Describe("RetryPolicy", func() {
+		var (
+			attempts int
+			observer *recordingObserver
+		)
+
+		failingTimes := func(n int, err error) func() error {
+			return func() error {
+				attempts++
+				if attempts <= n {
+					return err
+				}
+				return nil
+			}
+		}
+
+		BeforeEach(func() {
+			attempts = 0
+			observer = &recordingObserver{}
+		})
+
+		It("tries a failing step again until it succeeds", func() {
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Name:    "push",
+					Forward: failingTimes(2, errors.New("connection refused")),
+					Retry:   &rewind.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+				}},
+				Observer: observer,
+			}
+
+			Ω(actions.Execute()).Should(Succeed())
+			Ω(attempts).Should(Equal(3))
+			Ω(observer.events).Should(Equal([]string{
+				"started 0 push",
+				"retrying 0 push after attempt 1: connection refused",
+				"retrying 0 push after attempt 2: connection refused",
+				"succeeded 0 push",
+			}))
+		})
+
+		It("fails the step and rolls back once the attempts run out", func() {
+			undone := false
+			actions := rewind.Actions{
+				Actions: []rewind.Action{
+					{
+						Forward: func() error { return nil },
+						Reverse: func() error {
+							undone = true
+							return nil
+						},
+					},
+					{
+						Forward: failingTimes(5, errors.New("connection refused")),
+						Retry:   &rewind.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+					},
+				},
+			}
+
+			Ω(actions.Execute()).Should(MatchError("connection refused"))
+			Ω(attempts).Should(Equal(3))
+			Ω(undone).Should(BeTrue())
+		})
+
+		It("does not retry errors the policy does not consider retryable", func() {
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Forward: failingTimes(5, errors.New("insufficient resources")),
+					Retry: &rewind.RetryPolicy{
+						MaxAttempts: 3,
+						Retryable: func(err error) bool {
+							return err.Error() == "connection refused"
+						},
+					},
+				}},
+			}
+
+			Ω(actions.Execute()).Should(MatchError("insufficient resources"))
+			Ω(attempts).Should(Equal(1))
+		})
+
+		It("does not retry without a policy", func() {
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Forward: failingTimes(1, errors.New("connection refused")),
+				}},
+			}
+
+			Ω(actions.Execute()).Should(HaveOccurred())
+			Ω(attempts).Should(Equal(1))
+		})
+
+		It("stops waiting to retry when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			actions := rewind.Actions{
+				Actions: []rewind.Action{{
+					Forward: func() error {
+						attempts++
+						cancel()
+						return errors.New("connection refused")
+					},
+					Retry: &rewind.RetryPolicy{MaxAttempts: 3, Backoff: time.Hour},
+				}},
+			}
+
+			err := actions.ExecuteContext(ctx)
+			Ω(err.(*rewind.RewindError).Cancelled()).Should(BeTrue())
+			Ω(attempts).Should(Equal(1))
+		})
+
+		It("doubles the wait for each retry up to the limit", func() {
+			policy := &rewind.RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+			Ω(policy.Wait(1)).Should(Equal(time.Second))
+			Ω(policy.Wait(2)).Should(Equal(2 * time.Second))
+			Ω(policy.Wait(3)).Should(Equal(4 * time.Second))
+			Ω(policy.Wait(4)).Should(Equal(5 * time.Second))
+			Ω(policy.Wait(40)).Should(Equal(5 * time.Second))
+		})
+
+		It("takes up to the jitter off each wait", func() {
+			policy := &rewind.RetryPolicy{Backoff: time.Second, Jitter: 0.5}
+			for i := 0; i < 20; i++ {
+				Ω(policy.Wait(1)).Should(BeNumerically(">", 500*time.Millisecond))
+				Ω(policy.Wait(1)).Should(BeNumerically("<=", time.Second))
+			}
+		})
+	})
 })
+
+//recordingObserver - remembers every event it is told about
+type recordingObserver struct {
+	rewind.NopObserver
+	events    []string
+	durations []time.Duration
+	rewindErr error
+}
+
+func (o *recordingObserver) StepStarted(index int, action rewind.Action) {
+	o.events = append(o.events, fmt.Sprintf("started %d %s", index, action.Name))
+}
+
+func (o *recordingObserver) StepSucceeded(index int, action rewind.Action, duration time.Duration) {
+	o.events = append(o.events, fmt.Sprintf("succeeded %d %s", index, action.Name))
+	o.durations = append(o.durations, duration)
+}
+
+func (o *recordingObserver) StepFailed(index int, action rewind.Action, err error, duration time.Duration) {
+	o.events = append(o.events, fmt.Sprintf("failed %d %s: %s", index, action.Name, err))
+}
+
+func (o *recordingObserver) StepRetrying(index int, action rewind.Action, err error, attempt int, wait time.Duration) {
+	o.events = append(o.events, fmt.Sprintf("retrying %d %s after attempt %d: %s", index, action.Name, attempt, err))
+}
+
+func (o *recordingObserver) RollbackStarted(err error) {
+	o.events = append(o.events, fmt.Sprintf("rollback started: %s", err))
+}
+
+func (o *recordingObserver) StepReversed(index int, action rewind.Action, err error, duration time.Duration) {
+	o.events = append(o.events, fmt.Sprintf("reversed %d %s", index, action.Name))
+}
+
+func (o *recordingObserver) RollbackFinished(rewindErr *rewind.RewindError, duration time.Duration) {
+	o.events = append(o.events, fmt.Sprintf("rollback finished: %d reversals", len(rewindErr.Reversals)))
+	o.rewindErr = rewindErr
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//names of the steps taken by zdd-rollback
+const (
+	setAsideStep     = "set-aside"
+	reinstateStep    = "reinstate"
+	startStep        = "start"
+	ensureRoutesStep = "ensure-routes"
+	stopFailedStep   = "stop-failed"
+)
+
+//rollbackDeployment - swap the current app with its venerable app
+func (plugin AutopilotPlugin) rollbackDeployment(args []string) error {
+	if len(args) < 2 {
+		return ErrNoAppName
+	}
+	plugin.setAppName(args[1])
+	plugin.report = &report{}
+	keepFailed := hasFlag(args[2:], "--keep-failed")
+
+	apps, err := plugin.appRepo.ListApplicationsWithOutput()
+	if err != nil {
+		return err
+	}
+
+	if !contains(apps, plugin.venerableAppName) {
+		return fmt.Errorf("there is no %s to roll back to", plugin.venerableAppName)
+	}
+
+	actionList, err := plugin.getRollbackActions(keepFailed)
+	if err != nil {
+		return err
+	}
+
+	err = plugin.execute(actionList, "Rollback failed and could not be undone, you should check to see if everything is OK.")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s has been rolled back to the previous version\n\n", plugin.appName)
+	return plugin.appRepo.ListApplications()
+}
+
+//getRollbackActions - set the current app aside, put the venerable app back
+//in its place, and get rid of the version being rolled back
+func (plugin AutopilotPlugin) getRollbackActions(keepFailed bool) ([]rewind.Action, error) {
+	current, err := plugin.appRepo.GetApplication(plugin.appName)
+	if err != nil {
+		return nil, err
+	}
+
+	venerable, err := plugin.appRepo.GetApplication(plugin.venerableAppName)
+	if err != nil {
+		return nil, err
+	}
+
+	failedAppName := plugin.appName + "-rollback"
+	routesToAdd := missingRoutes(current.Routes, venerable.Routes)
+	_, baked := bakingUntil(venerable.EnvironmentVars)
+
+	actionList := []rewind.Action{
+		plugin.journaled(setAsideStep, rewind.Action{
+			Description: fmt.Sprintf("rename %s to %s", plugin.appName, failedAppName),
+			Retry:       noRetry,
+			Forward: func() error {
+				return plugin.appRepo.RenameApplication(plugin.appName, failedAppName)
+			},
+			Reverse: func() error {
+				return plugin.appRepo.RenameApplication(failedAppName, plugin.appName)
+			},
+		}),
+		plugin.journaled(reinstateStep, rewind.Action{
+			Description: fmt.Sprintf("rename %s to %s", plugin.venerableAppName, plugin.appName),
+			Retry:       noRetry,
+			Forward: func() error {
+				if err := plugin.appRepo.RenameApplication(plugin.venerableAppName, plugin.appName); err != nil || !baked {
+					return err
+				}
+				//it is no longer baking, and must not look it once it is replaced again
+				return plugin.appRepo.SetEnv(plugin.appName, bakeUntilEnv, "")
+			},
+			Reverse: func() error {
+				return plugin.appRepo.RenameApplication(plugin.appName, plugin.venerableAppName)
+			},
+		}),
+		plugin.journaled(startStep, rewind.Action{
+			Description: fmt.Sprintf("start %s", plugin.appName),
+			Forward: func() error {
+				return plugin.appRepo.StartApplication(plugin.appName)
+			},
+			Reverse: func() error {
+				return plugin.appRepo.StopApplication(plugin.appName)
+			},
+		}),
+		plugin.journaled(ensureRoutesStep, rewind.Action{
+			Description: fmt.Sprintf("map %s's missing routes to %s", failedAppName, plugin.appName),
+			Forward: func() error {
+				return plugin.mapRoutes(plugin.appName, routesToAdd...)
+			},
+			Reverse: func() error {
+				return plugin.unmapRoutes(plugin.appName, routesToAdd...)
+			},
+		}),
+	}
+	actionList = append(actionList, plugin.getVerificationActions(plugin.appName, failedAppName, nil)...)
+
+	if keepFailed {
+		return append(actionList, plugin.journaled(stopFailedStep, rewind.Action{
+			Description: fmt.Sprintf("stop %s", failedAppName),
+			Forward: func() error {
+				return plugin.appRepo.StopApplication(failedAppName)
+			},
+		})), nil
+	}
+
+	return append(actionList, plugin.journaled(discardStep, rewind.Action{
+		Description: fmt.Sprintf("delete %s", failedAppName),
+		Forward: func() error {
+			return plugin.appRepo.DeleteApplication(failedAppName)
+		},
+	})), nil
+}
+
+//missingRoutes - the routes in want which are not in have
+func missingRoutes(want, have []plugin_models.GetApp_RouteSummary) (missing []plugin_models.GetApp_RouteSummary) {
+	for _, route := range want {
+		found := false
+		for _, existing := range have {
+			if existing.Host == route.Host && existing.Domain.Name == route.Domain.Name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, route)
+		}
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package main_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("zdd-rollback", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		exitCode        int
+		restoreExit     func()
+	)
+
+	route := func(host string) plugin_models.GetApp_RouteSummary {
+		return plugin_models.GetApp_RouteSummary{Host: host, Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}}
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+			plugin_models.GetAppsModel{Name: "myapp-venerable"},
+		}, nil)
+		cliConn.GetAppStub = func(name string) (plugin_models.GetAppModel, error) {
+			if name == "myapp-venerable" {
+				return plugin_models.GetAppModel{Name: name, Routes: []plugin_models.GetApp_RouteSummary{route("myapp")}}, nil
+			}
+			return plugin_models.GetAppModel{Name: name, Routes: []plugin_models.GetApp_RouteSummary{route("myapp"), route("www")}}, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+	})
+
+	Context("when there is a venerable app", func() {
+		It("then it should swap it back in place of the current app", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-rollback", "myapp"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-rollback"},
+				{"rename", "myapp-venerable", "myapp"},
+				{"start", "myapp"},
+				{"map-route", "myapp", "example.com", "-n", "www"},
+				{"delete", "myapp-rollback", "-f"},
+			}))
+		})
+
+		It("then it should keep the failed version stopped when asked to", func() {
+			autopilotPlugin.Run(cliConn, []string{"zdd-rollback", "myapp", "--keep-failed"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)[4]).Should(Equal([]string{"stop", "myapp-rollback"}))
+		})
+	})
+
+	Context("when the previous version will not start", func() {
+		It("then it should undo the rollback", func() {
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				if args[0] == "start" {
+					return nil, errors.New("insufficient resources")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"zdd-rollback", "myapp"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-rollback"},
+				{"rename", "myapp-venerable", "myapp"},
+				{"start", "myapp"},
+				{"rename", "myapp", "myapp-venerable"},
+				{"rename", "myapp-rollback", "myapp"},
+			}))
+		})
+	})
+
+	Context("when the venerable app was kept to bake", func() {
+		It("then it should no longer be marked as baking once it is back in place", func() {
+			foundation := repofakes.NewFakeFoundation()
+			foundation.AddApp(repofakes.FakeApp{Name: "myapp"})
+			foundation.AddApp(repofakes.FakeApp{Name: "myapp-venerable", Env: map[string]string{"AUTOPILOT_BAKE_UNTIL": "2030-01-01T00:00:00Z"}})
+			restoreRepo := SetApplicationRepo(foundation)
+			defer restoreRepo()
+
+			autopilotPlugin.Run(cliConn, []string{"zdd-rollback", "myapp"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(foundation.App("myapp").Env).ShouldNot(HaveKey("AUTOPILOT_BAKE_UNTIL"))
+		})
+	})
+
+	Context("when there is no venerable app", func() {
+		It("then it should fail without touching any apps", func() {
+			cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+				plugin_models.GetAppsModel{Name: "myapp"},
+			}, nil)
+			autopilotPlugin.Run(cliConn, []string{"zdd-rollback", "myapp"})
+
+			Ω(exitCode).Should(Equal(1))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(0))
+		})
+	})
+})
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//verifyServicesStep - the name of the service binding check in the deployment journal
+const verifyServicesStep = "verify-services"
+
+//currentServices - the services bound to the version being replaced, which is
+//the venerable app when a stale one is being reused
+func (plugin AutopilotPlugin) currentServices(apps []string) ([]string, error) {
+	name := plugin.appName
+	if !contains(apps, name) {
+		if !plugin.staleVenerable(apps) || plugin.options.onStaleVenerable != staleReuse {
+			return nil, nil
+		}
+		name = plugin.venerableAppName
+	}
+
+	app, err := plugin.appRepo.GetApplication(name)
+	if err != nil {
+		return nil, err
+	}
+
+	services := []string{}
+	for _, service := range app.Services {
+		services = append(services, service.Name)
+	}
+	return services, nil
+}
+
+//requiredServices - the services the new version must be bound to, those of
+//the version it replaces less any --allow-service-removal
+func (plugin AutopilotPlugin) requiredServices() (required []string) {
+	for _, service := range plugin.services {
+		if !contains(plugin.options.allowedRemovals, service) {
+			required = append(required, service)
+		}
+	}
+	return
+}
+
+//getVerifyServicesAction - check the new version is bound to every service
+//in required, it may be bound to others as well
+func (plugin AutopilotPlugin) getVerifyServicesAction(appName string, required []string) rewind.Action {
+	return rewind.Action{
+		Description: fmt.Sprintf("check %s is bound to %s", appName, strings.Join(required, ", ")),
+		Forward: func() error {
+			app, err := plugin.appRepo.GetApplication(appName)
+			if err != nil {
+				return err
+			}
+
+			bound := []string{}
+			for _, service := range app.Services {
+				bound = append(bound, service.Name)
+			}
+
+			missing := []string{}
+			for _, service := range required {
+				if !contains(bound, service) {
+					missing = append(missing, service)
+				}
+			}
+
+			if len(missing) > 0 {
+				return fmt.Errorf("%s is not bound to %s which the current version is bound to, add them to the manifest or use --allow-service-removal for each one which should be removed",
+					appName, strings.Join(missing, ", "))
+			}
+			return nil
+		},
+	}
+}
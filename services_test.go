@@ -0,0 +1,123 @@
+package main_test
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Service bindings", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		newServices     []string
+		pushed          bool
+		output          *bytes.Buffer
+		exitCode        int
+		restores        []func()
+	)
+
+	bindings := func(names ...string) (services []plugin_models.GetApp_ServiceSummary) {
+		for _, name := range names {
+			services = append(services, plugin_models.GetApp_ServiceSummary{Name: name})
+		}
+		return
+	}
+
+	BeforeEach(func() {
+		pushed = false
+		newServices = []string{"db"}
+		output = &bytes.Buffer{}
+		restores = []func(){
+			recordExit(&exitCode),
+			SetStdout(output),
+		}
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				pushed = true
+			}
+			return nil, nil
+		}
+		cliConn.GetAppStub = func(name string) (plugin_models.GetAppModel, error) {
+			running := plugin_models.GetApp_AppInstanceFields{State: "running", Since: time.Now().Add(-time.Hour)}
+			app := plugin_models.GetAppModel{
+				Name:             name,
+				InstanceCount:    1,
+				RunningInstances: 1,
+				Instances:        []plugin_models.GetApp_AppInstanceFields{running},
+				Services:         bindings("db", "cache"),
+				Routes: []plugin_models.GetApp_RouteSummary{
+					{Host: "myapp", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+				},
+			}
+			if pushed && name != "myapp-venerable" {
+				app.Services = bindings(newServices...)
+			}
+			return app, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		for _, restore := range restores {
+			restore()
+		}
+	})
+
+	Context("when the new version is missing one of the current version's services", func() {
+		It("then it should roll back, naming the missing service", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(output.String()).Should(ContainSubstring("myapp is not bound to cache which the current version is bound to"))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"rename", "myapp", "myapp-venerable"},
+				{"push", "myapp"},
+				{"delete", "myapp", "-f"},
+				{"rename", "myapp-venerable", "myapp"},
+			}))
+		})
+
+		It("then it should check even when the new version is not started", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--no-start"})
+
+			Ω(exitCode).Should(Equal(3))
+		})
+
+		It("then it should check the green app when deploying blue-green", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--strategy", "blue-green"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(output.String()).Should(ContainSubstring("myapp-green is not bound to cache"))
+		})
+
+		It("then it should go ahead when the removal is allowed", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--allow-service-removal", "cache"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)[2]).Should(Equal([]string{"delete", "myapp-venerable", "-f"}))
+		})
+	})
+
+	Context("when the new version is bound to more services than the current version", func() {
+		It("then it should go ahead", func() {
+			newServices = []string{"db", "cache", "queue"}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(output.String()).Should(ContainSubstring("check myapp is bound to db, cache"))
+		})
+	})
+})
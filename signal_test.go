@@ -0,0 +1,138 @@
+//go:build !windows
+// +build !windows
+
+package main_test
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Cancelling a deployment", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		exitCode        int
+		restoreExit     func()
+		restoreSignals  func()
+		restoreGrace    func()
+	)
+
+	BeforeEach(func() {
+		exitCode = -1
+		restoreExit = recordExit(&exitCode)
+		//the test runner traps SIGINT and SIGTERM itself
+		restoreSignals = SetCancelSignals(syscall.SIGHUP)
+		restoreGrace = SetAbandonGrace(10 * time.Millisecond)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{
+				Name: "myapp",
+			},
+		}, nil)
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				syscall.Kill(os.Getpid(), syscall.SIGHUP)
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+		restoreSignals()
+		restoreGrace()
+	})
+
+	Context("when a signal arrives while the new version is being pushed", func() {
+		BeforeEach(func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp"})
+		})
+
+		It("then it should wait for the push, delete what it pushed and rename the venerable app back", func() {
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(4))
+			Ω(cliConn.CliCommandArgsForCall(0)).Should(Equal([]string{"rename", "myapp", "myapp-venerable"}))
+			Ω(cliConn.CliCommandArgsForCall(1)).Should(Equal([]string{"push", "myapp"}))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp", "-f"}))
+			Ω(cliConn.CliCommandArgsForCall(3)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+		})
+
+		It("then it should exit with an error", func() {
+			Ω(exitCode).Should(Equal(6))
+		})
+	})
+
+	Context("when a signal arrives while the app is being renamed", func() {
+		var (
+			foundation  *repofakes.FakeFoundation
+			original    string
+			restoreRepo func()
+		)
+
+		BeforeEach(func() {
+			foundation = repofakes.NewFakeFoundation()
+			original = foundation.AddApp(repofakes.FakeApp{Name: "myapp"}).Guid
+			interrupt := &sync.Once{}
+			foundation.AfterCall("RenameApplication", func(string) {
+				interrupt.Do(func() {
+					syscall.Kill(os.Getpid(), syscall.SIGHUP)
+					time.Sleep(50 * time.Millisecond)
+				})
+			})
+			restoreRepo = SetApplicationRepo(foundation)
+		})
+
+		AfterEach(func() {
+			restoreRepo()
+		})
+
+		It("then it should wait for the rename and rename the app back", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp"})
+
+			Ω(exitCode).Should(Equal(6))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(foundation.App("myapp").Guid).Should(Equal(original))
+		})
+
+		It("then it should rename the app back when the rename is abandoned by the api client", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--client", "api"})
+
+			Ω(exitCode).Should(Equal(6))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(foundation.App("myapp").Guid).Should(Equal(original))
+		})
+	})
+
+	Context("when a signal arrives once the new version has been pushed but before the push returns", func() {
+		It("then it should leave only the original app", func() {
+			foundation := repofakes.NewFakeFoundation()
+			original := foundation.AddApp(repofakes.FakeApp{Name: "myapp"}).Guid
+			foundation.AfterCall("PushApplication", func(string) {
+				syscall.Kill(os.Getpid(), syscall.SIGHUP)
+				time.Sleep(50 * time.Millisecond)
+			})
+			restoreRepo := SetApplicationRepo(foundation)
+			defer restoreRepo()
+
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--client", "api"})
+
+			Ω(exitCode).Should(Equal(6))
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+			Ω(foundation.App("myapp").Guid).Should(Equal(original))
+		})
+	})
+})
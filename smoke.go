@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/cli/plugin/models"
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//smokeStep - the name of the smoke test step in the deployment journal
+const smokeStep = "smoke-test"
+
+//smokeInterval - how long to wait between smoke test requests
+var smokeInterval = time.Second
+
+var smokeClient = &http.Client{Timeout: 10 * time.Second}
+
+//instanceHeader - the header telling the router which app instance a request
+//is for, so a route shared with the venerable app only reaches the new version
+const instanceHeader = "X-Cf-App-Instance"
+
+//getSmokeTestAction - request the smoke test url from the app's first route
+//until it has answered with the expected status the required number of times
+//in a row. Each request is sent to one of the app's own instances in turn
+func (plugin AutopilotPlugin) getSmokeTestAction(appName string) rewind.Action {
+	return rewind.Action{
+		Description: fmt.Sprintf("request %s on the first route of %s from its own instances until it returns %d %d times in a row",
+			plugin.options.smokeURL, appName, plugin.options.smokeExpect, plugin.options.smokeCount),
+		Retry: noRetry,
+		Forward: func() error {
+			app, err := plugin.appRepo.GetApplication(appName)
+			if err != nil {
+				return err
+			}
+
+			if len(app.Routes) == 0 {
+				return fmt.Errorf("%s has no routes to smoke test", appName)
+			}
+			url := routeURL(plugin.options.smokeScheme, app.Routes[0], plugin.options.smokeURL)
+			if app.Guid == "" {
+				return fmt.Errorf("%s has no guid to direct the smoke test to its own instances", appName)
+			}
+			instances := app.InstanceCount
+			if instances < 1 {
+				instances = 1
+			}
+
+			for i := 0; i < plugin.options.smokeCount; i++ {
+				if i > 0 {
+					time.Sleep(smokeInterval)
+				}
+
+				instance := fmt.Sprintf("%s:%d", app.Guid, i%instances)
+				if err = probe(url, instance, plugin.options.smokeExpect); err != nil {
+					return fmt.Errorf("smoke test of %s failed after %d successful requests: %s", appName, i, err)
+				}
+			}
+
+			plugin.printf("\nsmoke test of %s passed %d times\n\n", url, plugin.options.smokeCount)
+			return nil
+		},
+	}
+}
+
+//probe - GET url from the app instance named guid:index
+func probe(url, instance string, expect int) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(instanceHeader, instance)
+
+	resp, err := smokeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expect {
+		return fmt.Errorf("GET %s from instance %s returned %d, expected %d", url, instance, resp.StatusCode, expect)
+	}
+	return nil
+}
+
+//routeURL - the url of path on route
+func routeURL(scheme string, route plugin_models.GetApp_RouteSummary, path string) string {
+	host := route.Domain.Name
+	if route.Host != "" {
+		host = route.Host + "." + host
+	}
+
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, host, path)
+}
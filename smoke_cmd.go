@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//smokeCmdStep - the name of the smoke test command step in the deployment journal
+const smokeCmdStep = "smoke-command"
+
+//getSmokeCommandAction - run the smoke test command with the details of the
+//deployment in its environment, a non-zero exit fails the deployment
+func (plugin AutopilotPlugin) getSmokeCommandAction(appName, oldAppName string) rewind.Action {
+	return rewind.Action{
+		Description: fmt.Sprintf("run %q against %s", plugin.options.smokeCmd, appName),
+		Retry:       noRetry,
+		Forward: func() error {
+			env, err := plugin.smokeCommandEnv(appName, oldAppName)
+			if err != nil {
+				return err
+			}
+
+			cmd := shellCommand(plugin.options.smokeCmd)
+			cmd.Env = append(os.Environ(), env...)
+			output, err := cmd.CombinedOutput()
+			plugin.report.add(fmt.Sprintf("output of %s", plugin.options.smokeCmd), string(output))
+
+			if err != nil {
+				return fmt.Errorf("smoke command %q failed: %s", plugin.options.smokeCmd, err)
+			}
+			return nil
+		},
+	}
+}
+
+//smokeCommandEnv - the environment variables describing the deployment of
+//appName to replace oldAppName
+func (plugin AutopilotPlugin) smokeCommandEnv(appName, oldAppName string) ([]string, error) {
+	app, err := plugin.appRepo.GetApplication(appName)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := plugin.appRepo.ApiEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, len(app.Routes))
+	for i, route := range app.Routes {
+		urls[i] = routeURL(plugin.options.smokeScheme, route, "")
+	}
+
+	return []string{
+		"AUTOPILOT_APP_NAME=" + plugin.appName,
+		"AUTOPILOT_NEW_APP_NAME=" + appName,
+		"AUTOPILOT_VENERABLE_APP_NAME=" + oldAppName,
+		"AUTOPILOT_ROUTES=" + strings.Join(urls, ","),
+		"AUTOPILOT_API_ENDPOINT=" + endpoint,
+	}, nil
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+// +build !windows
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Smoke test command", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		dir             string
+		exitCode        int
+		restoreExit     func()
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "smoke-cmd")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		restoreExit = recordExit(&exitCode)
+
+		cliConn = newCliConnection()
+		cliConn.ApiEndpointReturns("https://api.example.com", nil)
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.GetAppReturns(plugin_models.GetAppModel{
+			Name: "myapp",
+			Routes: []plugin_models.GetApp_RouteSummary{
+				{Host: "myapp", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+				{Domain: plugin_models.GetApp_DomainFields{Name: "apps.example.com"}},
+			},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		restoreExit()
+	})
+
+	Context("when the command succeeds", func() {
+		It("then it should describe the deployment in the command's environment and delete the venerable app", func() {
+			envFile := filepath.Join(dir, "env")
+			autopilotPlugin.Run(cliConn, []string{
+				"push-zdd", "myapp",
+				"--smoke-cmd", "env | grep ^AUTOPILOT_ | grep -v ^AUTOPILOT_JOURNAL_DIR | sort > " + envFile,
+			})
+
+			Ω(exitCode).Should(Equal(0))
+			env, err := ioutil.ReadFile(envFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(env)).Should(Equal(
+				"AUTOPILOT_API_ENDPOINT=https://api.example.com\n" +
+					"AUTOPILOT_APP_NAME=myapp\n" +
+					"AUTOPILOT_NEW_APP_NAME=myapp\n" +
+					"AUTOPILOT_ROUTES=https://myapp.example.com,https://apps.example.com\n" +
+					"AUTOPILOT_VENERABLE_APP_NAME=myapp-venerable\n",
+			))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp-venerable", "-f"}))
+		})
+	})
+
+	Context("when the strategy is blue-green", func() {
+		It("then it should name the old version by its current name", func() {
+			cliConn.GetAppStub = func(name string) (plugin_models.GetAppModel, error) {
+				return plugin_models.GetAppModel{
+					Name:             name,
+					State:            "started",
+					InstanceCount:    1,
+					RunningInstances: 1,
+					Routes: []plugin_models.GetApp_RouteSummary{
+						{Host: "myapp", Domain: plugin_models.GetApp_DomainFields{Name: "example.com"}},
+					},
+				}, nil
+			}
+
+			envFile := filepath.Join(dir, "env")
+			autopilotPlugin.Run(cliConn, []string{
+				"push-zdd", "myapp", "--strategy", "blue-green",
+				"--smoke-cmd", "env | grep '^AUTOPILOT_[A-Z]*_APP_NAME' | sort > " + envFile,
+			})
+
+			Ω(exitCode).Should(Equal(0))
+			env, err := ioutil.ReadFile(envFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(env)).Should(Equal(
+				"AUTOPILOT_NEW_APP_NAME=myapp-green\n" +
+					"AUTOPILOT_VENERABLE_APP_NAME=myapp\n",
+			))
+		})
+	})
+
+	Context("when the command exits with an error", func() {
+		It("then it should roll back", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--smoke-cmd", "echo broken; exit 3"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(4))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp", "-f"}))
+			Ω(cliConn.CliCommandArgsForCall(3)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+		})
+	})
+})
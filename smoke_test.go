@@ -0,0 +1,113 @@
+package main_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Smoke test", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		server          *httptest.Server
+		requests        int32
+		failFrom        int32
+		newFails        bool
+		exitCode        int
+		restoreExit     func()
+		restoreInterval func()
+	)
+
+	BeforeEach(func() {
+		requests = 0
+		failFrom = -1
+		newFails = false
+		restoreExit = recordExit(&exitCode)
+		restoreInterval = SetSmokeInterval(0)
+
+		//the route is shared with the venerable app, which answers anything
+		//not sent to one of the new version's instances
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.Header.Get("X-Cf-App-Instance"), "new-guid:") {
+				return
+			}
+			n := atomic.AddInt32(&requests, 1)
+			if r.URL.Path != "/health" || newFails || (failFrom >= 0 && n > failFrom) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.GetAppReturns(plugin_models.GetAppModel{
+			Guid: "new-guid",
+			Name: "myapp",
+			Routes: []plugin_models.GetApp_RouteSummary{
+				{Domain: plugin_models.GetApp_DomainFields{Name: strings.TrimPrefix(server.URL, "http://")}},
+			},
+		}, nil)
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		restoreExit()
+		restoreInterval()
+	})
+
+	run := func() {
+		autopilotPlugin.Run(cliConn, []string{
+			"push-zdd", "myapp",
+			"--smoke-url", "/health",
+			"--smoke-expect", "200",
+			"--smoke-count", "3",
+			"--smoke-scheme", "http",
+		})
+	}
+
+	Context("when the new version answers every request", func() {
+		It("then it should delete the venerable app", func() {
+			run()
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(atomic.LoadInt32(&requests)).Should(Equal(int32(3)))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp-venerable", "-f"}))
+		})
+	})
+
+	Context("when only the venerable app on the same route answers", func() {
+		It("then it should roll back", func() {
+			newFails = true
+			run()
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp", "-f"}))
+			Ω(cliConn.CliCommandArgsForCall(3)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+		})
+	})
+
+	Context("when the new version stops answering part way through", func() {
+		It("then it should roll back", func() {
+			failFrom = 1
+			run()
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(atomic.LoadInt32(&requests)).Should(Equal(int32(2)))
+			Ω(cliConn.CliCommandCallCount()).Should(Equal(4))
+			Ω(cliConn.CliCommandArgsForCall(2)).Should(Equal([]string{"delete", "myapp", "-f"}))
+			Ω(cliConn.CliCommandArgsForCall(3)).Should(Equal([]string{"rename", "myapp-venerable", "myapp"}))
+		})
+	})
+})
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/xchapter7x/autopilot/rewind"
+)
+
+//deleteStaleStep - the step which deletes a venerable app left over from an
+//earlier deployment
+const deleteStaleStep = "delete-stale"
+
+//staleVenerable - whether a venerable app is left over from a deployment
+//which never finished, rather than kept on purpose to bake
+func (plugin AutopilotPlugin) staleVenerable(apps []string) bool {
+	if !contains(apps, plugin.venerableAppName) {
+		return false
+	}
+	_, baking := plugin.bakeDeadline()
+	return !baking
+}
+
+//checkStaleVenerable - whether the deployment can go ahead with the venerable
+//app left over from an earlier deployment, given --on-stale-venerable
+func (plugin AutopilotPlugin) checkStaleVenerable(apps []string) string {
+	if !plugin.staleVenerable(apps) {
+		return ""
+	}
+
+	switch plugin.options.onStaleVenerable {
+	case staleDelete:
+		if !contains(apps, plugin.appName) {
+			//it is the only version left, most likely still serving the routes
+			return fmt.Sprintf("%s is the only version of %s left and cannot be deleted, use --on-stale-venerable reuse or cf zdd-recover %s instead", plugin.venerableAppName, plugin.appName, plugin.appName)
+		}
+		return ""
+	case staleReuse:
+		if contains(apps, plugin.appName) {
+			return fmt.Sprintf("%s can only be reused when %s does not exist, use --on-stale-venerable delete instead", plugin.venerableAppName, plugin.appName)
+		}
+		if plugin.options.strategy != manifestStrategy {
+			return fmt.Sprintf("%s can only be reused with the %s strategy", plugin.venerableAppName, manifestStrategy)
+		}
+		return ""
+	}
+	return fmt.Sprintf("%s already exists, use cf zdd-recover %s or --on-stale-venerable delete|reuse", plugin.venerableAppName, plugin.appName)
+}
+
+//checkStaleGreen - a blue-green deployment needs the name of the green app
+//an earlier one may have left behind
+func (plugin AutopilotPlugin) checkStaleGreen(apps []string) string {
+	greenAppName := plugin.appName + "-green"
+	if plugin.options.strategy != blueGreenStrategy || !contains(apps, greenAppName) {
+		return ""
+	}
+	return fmt.Sprintf("%s already exists, left over from an earlier blue-green deployment, use cf zdd-recover %s or delete it", greenAppName, plugin.appName)
+}
+
+//getStaleVenerableActions - delete a venerable app left over from an earlier
+//deployment when asked to, so this deployment can use the name
+func (plugin AutopilotPlugin) getStaleVenerableActions(apps []string) []rewind.Action {
+	if plugin.options.onStaleVenerable != staleDelete || !plugin.staleVenerable(apps) {
+		return nil
+	}
+
+	plugin.printf("\n%s was left over from an earlier deployment, it will be deleted first\n\n", plugin.venerableAppName)
+	return []rewind.Action{
+		plugin.journaled(deleteStaleStep, plugin.getDeleteAction()),
+	}
+}
+
+//getReuseActions - treat a venerable app left over from a deployment which
+//renamed the app but never pushed its replacement as the version to replace
+func (plugin AutopilotPlugin) getReuseActions(argList []string) []rewind.Action {
+	plugin.printf("\n%s was not found but %s was, replacing it with a new version\n\n", plugin.appName, plugin.venerableAppName)
+	pushAction := plugin.getPushAction(argList)
+	plugin.addReversePrevious(&pushAction)
+
+	actionList := []rewind.Action{
+		plugin.journaled(pushStep, pushAction),
+	}
+	actionList = append(actionList, plugin.getVerificationActions(plugin.appName, plugin.venerableAppName, argList)...)
+	return append(actionList, plugin.getRetireAction())
+}
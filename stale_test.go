@@ -0,0 +1,130 @@
+package main_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Finding the app to replace", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		exitCode        int
+		restoreExit     func()
+	)
+
+	withApps := func(names ...string) {
+		apps := []plugin_models.GetAppsModel{}
+		for _, name := range names {
+			apps = append(apps, plugin_models.GetAppsModel{Name: name})
+		}
+		cliConn.GetAppsReturns(apps, nil)
+	}
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+
+		cliConn = newCliConnection()
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+	})
+
+	Context("when another app's name contains the app's name", func() {
+		It("then it should push the app as a new one", func() {
+			withApps("api-gateway", "my-api")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"push", "api"},
+			}))
+		})
+	})
+
+	Context("when a venerable app is left over from an earlier deployment", func() {
+		It("then it should fail by default", func() {
+			withApps("api", "api-venerable")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cfCommands(cliConn)).Should(BeEmpty())
+		})
+
+		It("then it should delete it first when asked to", func() {
+			withApps("api", "api-venerable")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api", "--on-stale-venerable", "delete"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"delete", "api-venerable", "-f"},
+				{"rename", "api", "api-venerable"},
+				{"push", "api"},
+				{"delete", "api-venerable", "-f"},
+			}))
+		})
+
+		It("then it should refuse to delete it when the app is gone", func() {
+			withApps("api-venerable")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api", "--on-stale-venerable", "delete"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cfCommands(cliConn)).Should(BeEmpty())
+		})
+
+		It("then it should replace it when asked to reuse it and the app is gone", func() {
+			withApps("api-venerable")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api", "--on-stale-venerable", "reuse"})
+
+			Ω(exitCode).Should(Equal(0))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"push", "api"},
+				{"delete", "api-venerable", "-f"},
+			}))
+		})
+
+		It("then it should leave it alone if its replacement fails to push", func() {
+			withApps("api-venerable")
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				if args[0] == "push" {
+					return nil, errors.New("push failed")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api", "--on-stale-venerable", "reuse"})
+
+			Ω(exitCode).Should(Equal(3))
+			Ω(cfCommands(cliConn)).Should(Equal([][]string{
+				{"push", "api"},
+				{"delete", "api", "-f"},
+			}))
+		})
+
+		It("then it should refuse to reuse it while the app still exists", func() {
+			withApps("api", "api-venerable")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api", "--on-stale-venerable", "reuse"})
+
+			Ω(exitCode).Should(Equal(2))
+			Ω(cfCommands(cliConn)).Should(BeEmpty())
+		})
+	})
+
+	Context("when --on-stale-venerable is not understood", func() {
+		It("then it should fail", func() {
+			withApps("api")
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "api", "--on-stale-venerable", "ignore"})
+
+			Ω(exitCode).Should(Equal(1))
+			Ω(cfCommands(cliConn)).Should(BeEmpty())
+		})
+	})
+})
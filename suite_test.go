@@ -1,9 +1,16 @@
 package main_test
 
 import (
+	"io/ioutil"
+	"os"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	. "github.com/xchapter7x/autopilot"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+
 	"testing"
 )
 
@@ -11,3 +18,42 @@ func TestSuite(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Suite")
 }
+
+var journalDir string
+
+var _ = BeforeSuite(func() {
+	var err error
+	journalDir, err = ioutil.TempDir("", "autopilot-journal")
+	Ω(err).ShouldNot(HaveOccurred())
+	os.Setenv("AUTOPILOT_JOURNAL_DIR", journalDir)
+})
+
+var _ = AfterSuite(func() {
+	os.Unsetenv("AUTOPILOT_JOURNAL_DIR")
+	os.RemoveAll(journalDir)
+})
+
+//newCliConnection - a fake cf cli which is logged in with a space targeted
+func newCliConnection() *fakes.FakeCliConnection {
+	cliConn := &fakes.FakeCliConnection{}
+	cliConn.IsLoggedInReturns(true, nil)
+	cliConn.HasSpaceReturns(true, nil)
+	return cliConn
+}
+
+//cfCommands - the args of every cf command run through cliConn, in order
+func cfCommands(cliConn *fakes.FakeCliConnection) (called [][]string) {
+	for i := 0; i < cliConn.CliCommandCallCount(); i++ {
+		called = append(called, cliConn.CliCommandArgsForCall(i))
+	}
+	return
+}
+
+//recordExit - have the plugin set code instead of exiting, starting from 0,
+//the returned func puts exiting back
+func recordExit(code *int) func() {
+	*code = 0
+	return SetExit(func(exitCode int) {
+		*code = exitCode
+	})
+}
@@ -0,0 +1,139 @@
+package main_test
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/xchapter7x/autopilot"
+	repofakes "github.com/xchapter7x/autopilot/application_repo/fakes"
+	"github.com/xchapter7x/autopilot/journal"
+
+	"github.com/cloudfoundry/cli/plugin/fakes"
+	"github.com/cloudfoundry/cli/plugin/models"
+)
+
+var _ = Describe("Timeouts", func() {
+	var (
+		cliConn         *fakes.FakeCliConnection
+		autopilotPlugin *AutopilotPlugin
+		exitCode        int
+		restoreExit     func()
+		restoreGrace    func()
+	)
+
+	BeforeEach(func() {
+		restoreExit = recordExit(&exitCode)
+		restoreGrace = SetAbandonGrace(10 * time.Millisecond)
+
+		cliConn = newCliConnection()
+		cliConn.GetAppsReturns([]plugin_models.GetAppsModel{
+			plugin_models.GetAppsModel{Name: "myapp"},
+		}, nil)
+		cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+			if args[0] == "push" {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil, nil
+		}
+		autopilotPlugin = &AutopilotPlugin{}
+	})
+
+	AfterEach(func() {
+		restoreExit()
+		restoreGrace()
+	})
+
+	rolledBack := [][]string{
+		{"rename", "myapp", "myapp-venerable"},
+		{"push", "myapp"},
+		{"delete", "myapp", "-f"},
+		{"rename", "myapp-venerable", "myapp"},
+	}
+
+	Context("when a step takes longer than --step-timeout", func() {
+		It("then it should roll back and exit with the timeout code", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--step-timeout", "20ms"})
+
+			Ω(exitCode).Should(Equal(5))
+			Ω(cfCommands(cliConn)).Should(Equal(rolledBack))
+		})
+
+		It("then it should wait for the cf command in flight before rolling back", func() {
+			var pushing int32
+			deletedWhilePushing := false
+			foundation := repofakes.NewFakeFoundation()
+			foundation.AddApp(repofakes.FakeApp{Name: "myapp"})
+			foundation.AfterCall("PushApplication", func(string) {
+				atomic.StoreInt32(&pushing, 1)
+				time.Sleep(50 * time.Millisecond)
+				atomic.StoreInt32(&pushing, 0)
+			})
+			foundation.AfterCall("DeleteApplication", func(string) {
+				deletedWhilePushing = atomic.LoadInt32(&pushing) == 1
+			})
+			restoreRepo := SetApplicationRepo(foundation)
+			defer restoreRepo()
+
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--step-timeout", "20ms"})
+
+			Ω(exitCode).Should(Equal(5))
+			Ω(deletedWhilePushing).Should(BeFalse())
+			Ω(foundation.AppNames()).Should(Equal([]string{"myapp"}))
+		})
+	})
+
+	Context("when an abandoned step finishes after the rollback", func() {
+		It("then it should not journal the step as having succeeded", func() {
+			release := make(chan struct{})
+			foundation := repofakes.NewFakeFoundation()
+			foundation.AddApp(repofakes.FakeApp{Name: "myapp"})
+			foundation.AfterCall("PushApplication", func(string) {
+				<-release
+			})
+			restoreRepo := SetApplicationRepo(foundation)
+			defer restoreRepo()
+
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--step-timeout", "20ms", "--client", "api"})
+			close(release)
+
+			appJournal := journal.New(journal.TargetDir(journal.DefaultDir(), "https://api.example.com", "my-org", "my-space"), "myapp")
+			defer os.Remove(appJournal.Path())
+			Consistently(func() string {
+				deployment, err := appJournal.Latest()
+				Ω(err).ShouldNot(HaveOccurred())
+				return deployment.Outcome("push")
+			}, 100*time.Millisecond).Should(Equal(journal.Reversed))
+		})
+	})
+
+	Context("when the deployment takes longer than --deploy-timeout", func() {
+		It("then it should roll back and exit with the timeout code", func() {
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--deploy-timeout", "20ms"})
+
+			Ω(exitCode).Should(Equal(5))
+			Ω(cfCommands(cliConn)).Should(Equal(rolledBack))
+		})
+	})
+
+	Context("when the rollback after a timeout fails", func() {
+		It("then it should exit with the rollback failed code", func() {
+			cliConn.CliCommandStub = func(args ...string) ([]string, error) {
+				switch args[0] {
+				case "push":
+					time.Sleep(50 * time.Millisecond)
+				case "delete":
+					return nil, errors.New("delete failed")
+				}
+				return nil, nil
+			}
+			autopilotPlugin.Run(cliConn, []string{"push-zdd", "myapp", "--step-timeout", "20ms"})
+
+			Ω(exitCode).Should(Equal(4))
+		})
+	})
+})